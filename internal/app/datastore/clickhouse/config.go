@@ -0,0 +1,153 @@
+package clickhouse
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Config describes clickhouse connection and order book storage
+type Config struct {
+	// DSN is used as is when set, otherwise it is built from the connection settings below
+	DSN      string
+	Address  string
+	Database string
+	Username string
+	Password string
+	// TLS enables secure connection when set
+	TLS   *tls.Config
+	Debug bool
+	// Connection pool limits, zero keeps database/sql defaults
+	MaxOpenConns int
+	MaxIdleConns int
+
+	OrdersTable      string
+	TradesTable      string
+	MigrationsTable  string
+	InstrumentsTable string
+	// AutoMigrate applies pending migrations on start
+	AutoMigrate bool
+}
+
+// Option changes default config
+type Option func(*Config)
+
+// DefaultConfig points to a local server with default credentials
+func DefaultConfig() Config {
+	return Config{
+		Address:          "127.0.0.1:9000",
+		Database:         "default",
+		Username:         "default",
+		OrdersTable:      "orders",
+		TradesTable:      "trades",
+		MigrationsTable:  "schema_migrations",
+		InstrumentsTable: "instruments",
+		AutoMigrate:      true,
+	}
+}
+
+func WithDSN(dsn string) Option {
+	return func(c *Config) { c.DSN = dsn }
+}
+
+func WithAddress(address string) Option {
+	return func(c *Config) { c.Address = address }
+}
+
+func WithDatabase(database string) Option {
+	return func(c *Config) { c.Database = database }
+}
+
+func WithCredentials(username, password string) Option {
+	return func(c *Config) {
+		c.Username = username
+		c.Password = password
+	}
+}
+
+func WithTLS(tlsConfig *tls.Config) Option {
+	return func(c *Config) { c.TLS = tlsConfig }
+}
+
+func WithDebug(debug bool) Option {
+	return func(c *Config) { c.Debug = debug }
+}
+
+func WithPool(maxOpenConns, maxIdleConns int) Option {
+	return func(c *Config) {
+		c.MaxOpenConns = maxOpenConns
+		c.MaxIdleConns = maxIdleConns
+	}
+}
+
+func WithTables(ordersTable, tradesTable string) Option {
+	return func(c *Config) {
+		c.OrdersTable = ordersTable
+		c.TradesTable = tradesTable
+	}
+}
+
+func WithMigrationsTable(migrationsTable string) Option {
+	return func(c *Config) { c.MigrationsTable = migrationsTable }
+}
+
+func WithInstrumentsTable(instrumentsTable string) Option {
+	return func(c *Config) { c.InstrumentsTable = instrumentsTable }
+}
+
+func WithAutoMigrate(autoMigrate bool) Option {
+	return func(c *Config) { c.AutoMigrate = autoMigrate }
+}
+
+func newConfig(options []Option) Config {
+	config := DefaultConfig()
+	for _, option := range options {
+		option(&config)
+	}
+
+	return config
+}
+
+// Driver keeps TLS configs in a global registry, so every config gets its own key
+var tlsConfigKeys uint64
+
+// dsn builds driver connection string, TLS config gets registered in the driver on the way
+func (c Config) dsn() (string, error) {
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+
+	query := url.Values{}
+	query.Set("database", c.Database)
+	query.Set("username", c.Username)
+	query.Set("password", c.Password)
+	query.Set("compress", "true")
+	query.Set("debug", strconv.FormatBool(c.Debug))
+
+	if c.TLS != nil {
+		key := fmt.Sprintf("orderbook-%d", atomic.AddUint64(&tlsConfigKeys, 1))
+		if err := clickhouse.RegisterTLSConfig(key, c.TLS); err != nil {
+			return "", err
+		}
+
+		query.Set("secure", "true")
+		query.Set("tls_config", key)
+	}
+
+	return (&url.URL{Scheme: "tcp", Host: c.Address, RawQuery: query.Encode()}).String(), nil
+}
+
+// placeholders substitutes configured table names and price storage scale into queries
+func (c Config) placeholders() *strings.Replacer {
+	return strings.NewReplacer(
+		"{orders}", c.OrdersTable,
+		"{trades}", c.TradesTable,
+		"{migrations}", c.MigrationsTable,
+		"{instruments}", c.InstrumentsTable,
+		"{priceScale}", strconv.Itoa(int(priceScale)),
+	)
+}
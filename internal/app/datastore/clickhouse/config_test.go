@@ -0,0 +1,89 @@
+package clickhouse
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+)
+
+func TestNewConfig(t *testing.T) {
+	assert.Equal(t, DefaultConfig(), newConfig(nil))
+
+	tlsConfig := &tls.Config{ServerName: "clickhouse"}
+	config := newConfig([]Option{
+		WithDSN("tcp://clickhouse:9000"),
+		WithAddress("clickhouse:9440"),
+		WithDatabase("exchange"),
+		WithCredentials("user", "secret"),
+		WithTLS(tlsConfig),
+		WithDebug(true),
+		WithPool(10, 5),
+		WithTables("test_orders", "test_trades"),
+		WithMigrationsTable("test_migrations"),
+		WithInstrumentsTable("test_instruments"),
+		WithAutoMigrate(false),
+	})
+
+	assert.Equal(t, Config{
+		DSN:              "tcp://clickhouse:9000",
+		Address:          "clickhouse:9440",
+		Database:         "exchange",
+		Username:         "user",
+		Password:         "secret",
+		TLS:              tlsConfig,
+		Debug:            true,
+		MaxOpenConns:     10,
+		MaxIdleConns:     5,
+		OrdersTable:      "test_orders",
+		TradesTable:      "test_trades",
+		MigrationsTable:  "test_migrations",
+		InstrumentsTable: "test_instruments",
+		AutoMigrate:      false,
+	}, config)
+}
+
+func TestConfig_dsn(t *testing.T) {
+	dsn, err := newConfig([]Option{WithDSN("tcp://clickhouse:9000?debug=true")}).dsn()
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp://clickhouse:9000?debug=true", dsn)
+
+	dsn, err = newConfig([]Option{
+		WithAddress("clickhouse:9000"),
+		WithDatabase("exchange"),
+		WithCredentials("user", "p@ss&word"),
+	}).dsn()
+	assert.Nil(t, err)
+
+	parsed, err := url.Parse(dsn)
+	assert.Nil(t, err)
+	assert.Equal(t, "tcp", parsed.Scheme)
+	assert.Equal(t, "clickhouse:9000", parsed.Host)
+	assert.Equal(t, "exchange", parsed.Query().Get("database"))
+	assert.Equal(t, "user", parsed.Query().Get("username"))
+	assert.Equal(t, "p@ss&word", parsed.Query().Get("password"))
+	assert.Equal(t, "true", parsed.Query().Get("compress"))
+	assert.Equal(t, "false", parsed.Query().Get("debug"))
+	assert.Empty(t, parsed.Query().Get("secure"))
+
+	dsn, err = newConfig([]Option{WithTLS(&tls.Config{})}).dsn()
+	assert.Nil(t, err)
+
+	parsed, _ = url.Parse(dsn)
+	assert.Equal(t, "true", parsed.Query().Get("secure"))
+	assert.NotEmpty(t, parsed.Query().Get("tls_config"))
+}
+
+func TestConfig_placeholders(t *testing.T) {
+	placeholders := newConfig([]Option{
+		WithTables("test_orders", "test_trades"),
+		WithMigrationsTable("test_migrations"),
+		WithInstrumentsTable("test_instruments"),
+	}).placeholders()
+
+	assert.Equal(t, "SELECT * FROM test_orders", placeholders.Replace("SELECT * FROM {orders}"))
+	assert.Equal(t, "SELECT * FROM test_trades", placeholders.Replace("SELECT * FROM {trades}"))
+	assert.Equal(t, "SELECT * FROM test_migrations", placeholders.Replace("SELECT * FROM {migrations}"))
+	assert.Equal(t, "SELECT * FROM test_instruments", placeholders.Replace("SELECT * FROM {instruments}"))
+	assert.Equal(t, "toDecimal64(?, 8)", placeholders.Replace("toDecimal64(?, {priceScale})"))
+}
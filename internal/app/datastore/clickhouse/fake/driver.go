@@ -0,0 +1,173 @@
+// Package fake is an in-process stand-in for a clickhouse server.
+// It understands the subset of clickhouse SQL issued by the clickhouse order book
+// and converts values the same way as the native driver does
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+)
+
+// DriverName to open a connection to the stand-in with, DSN names a database living as long as the process
+const DriverName = "clickhouse-fake"
+
+func init() {
+	sql.Register(DriverName, &fakeDriver{servers: make(map[string]*server)})
+}
+
+type fakeDriver struct {
+	mu      sync.Mutex
+	servers map[string]*server
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.servers[dsn]
+	if !ok {
+		s = newServer()
+		d.servers[dsn] = s
+	}
+
+	return &conn{server: s}, nil
+}
+
+type conn struct {
+	server *server
+	tx     *tx
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	statement, params, err := parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inserts are checked against the table on prepare, the same way the server does
+	if statement, ok := statement.(insert); ok {
+		if c.tx == nil {
+			return nil, errors.New("insert statement supported only in the batch mode (use begin/commit)")
+		}
+
+		c.server.mu.Lock()
+		_, err := c.server.table(statement.table)
+		c.server.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &stmt{conn: c, statement: statement, params: params}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("transaction is already begun")
+	}
+
+	c.tx = &tx{conn: c, block: make(map[string][]row)}
+	return c.tx, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	return nil
+}
+
+// tx collects inserted rows into a block which is sent on commit
+type tx struct {
+	conn  *conn
+	block map[string][]row
+}
+
+func (t *tx) Commit() error {
+	t.conn.tx = nil
+	return t.conn.server.insert(t.block)
+}
+
+func (t *tx) Rollback() error {
+	t.conn.tx = nil
+	return nil
+}
+
+type stmt struct {
+	conn      *conn
+	statement interface{}
+	params    int
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.params
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	statement, ok := s.statement.(insert)
+	if !ok {
+		return driver.RowsAffected(0), s.conn.server.exec(s.statement, args)
+	}
+
+	if s.conn.tx == nil {
+		return nil, errors.New("insert statement supported only in the batch mode (use begin/commit)")
+	}
+
+	name, r, err := s.conn.server.newRow(statement, args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.conn.tx.block[name] = append(s.conn.tx.block[name], r)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	statement, ok := s.statement.(*selectStatement)
+	if !ok {
+		return nil, errors.New("statement doesn't return rows")
+	}
+
+	selected, err := s.conn.server.query(statement, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{result: selected}, nil
+}
+
+type rows struct {
+	result *result
+	next   int
+}
+
+func (r *rows) Columns() []string {
+	return r.result.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+
+	selected := r.result.rows[r.next]
+	for i, column := range r.result.columns {
+		dest[i] = toDriver(selected[column])
+	}
+
+	r.next++
+	return nil
+}
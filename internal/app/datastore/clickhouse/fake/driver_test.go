@@ -0,0 +1,205 @@
+package fake
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(DriverName, uuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS items (
+			id UUID,
+			price Decimal64(8),
+			name String,
+			createdAt DateTime64(9, 'UTC'),
+			version UInt64
+		)
+		ENGINE = ReplacingMergeTree(version)
+		ORDER BY id
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func insertItem(t *testing.T, db *sql.DB, id uuid.UUID, price int64, name string, version uint64) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO items (id, price, name, createdAt, version) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(id, price, name, time.Unix(0, 1500), version); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriver_Insert(t *testing.T) {
+	db := openDB(t)
+	id := uuid.New()
+
+	// Inserts need a transaction, just like with the native driver
+	_, err := db.Exec(`INSERT INTO items (id, price, name, createdAt, version) VALUES (?, ?, ?, ?, ?)`, id, 1, "a", time.Now(), 1)
+	assert.NotNil(t, err)
+
+	// Decimals are written as unscaled integers and strings are rejected
+	tx, _ := db.Begin()
+	stmt, err := tx.Prepare(`INSERT INTO items (id, price) VALUES (?, ?)`)
+	assert.Nil(t, err)
+	_, err = stmt.Exec(id, "10.5")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+
+	insertItem(t, db, id, 1050000000, "item", 1)
+
+	var (
+		price     int64
+		name      string
+		createdAt time.Time
+	)
+	err = db.QueryRow(`SELECT price, name, createdAt FROM items WHERE id = ?`, id).Scan(&price, &name, &createdAt)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1050000000), price)
+	assert.Equal(t, "item", name)
+	assert.Equal(t, int64(1500), createdAt.UnixNano())
+
+	// Rolled back rows are never seen
+	tx, _ = db.Begin()
+	stmt, _ = tx.Prepare(`INSERT INTO items (id, price) VALUES (?, ?)`)
+	_, err = stmt.Exec(uuid.New(), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+
+	var count int64
+	assert.Nil(t, db.QueryRow(`SELECT count() FROM items`).Scan(&count))
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDriver_Final(t *testing.T) {
+	db := openDB(t)
+	id := uuid.New()
+
+	insertItem(t, db, id, 1, "old", 1)
+	insertItem(t, db, id, 2, "new", 2)
+	insertItem(t, db, id, 3, "stale", 0)
+
+	// Versions are not collapsed without FINAL, the same way as before a merge
+	var count int64
+	assert.Nil(t, db.QueryRow(`SELECT count() FROM items WHERE id = ?`, id).Scan(&count))
+	assert.Equal(t, int64(3), count)
+
+	var name string
+	assert.Nil(t, db.QueryRow(`SELECT name FROM items FINAL WHERE id = ?`, id).Scan(&name))
+	assert.Equal(t, "new", name)
+}
+
+func TestDriver_Select(t *testing.T) {
+	db := openDB(t)
+
+	insertItem(t, db, uuid.New(), 300000001, "b", 1)
+	insertItem(t, db, uuid.New(), 300000002, "a", 1)
+	insertItem(t, db, uuid.New(), 300000002, "c", 1)
+
+	rows, err := db.Query(`
+		SELECT price, count() AS items
+		FROM items
+		WHERE price >= toDecimal64(?, 8)
+		GROUP BY price
+		ORDER BY price DESC
+		LIMIT ?
+	`, "3.00000001", 1)
+	assert.Nil(t, err)
+
+	columns, _ := rows.Columns()
+	assert.Equal(t, []string{"price", "items"}, columns)
+
+	var price, items int64
+	assert.True(t, rows.Next())
+	assert.Nil(t, rows.Scan(&price, &items))
+	assert.Equal(t, int64(300000002), price)
+	assert.Equal(t, int64(2), items)
+	assert.False(t, rows.Next())
+
+	// Aggregation over nothing gives defaults
+	var version int64
+	assert.Nil(t, db.QueryRow(`SELECT max(version) FROM items WHERE name = 'none'`).Scan(&version))
+	assert.Zero(t, version)
+
+	var names int64
+	assert.Nil(t, db.QueryRow(`SELECT count() FROM items WHERE name IN ('a', 'b')`).Scan(&names))
+	assert.Equal(t, int64(2), names)
+	assert.Nil(t, db.QueryRow(`SELECT count() FROM items WHERE name NOT IN ('a', ?)`, "b").Scan(&names))
+	assert.Equal(t, int64(1), names)
+	assert.Nil(t, db.QueryRow(`SELECT count() FROM items WHERE name IN ('c')`).Scan(&names))
+	assert.Equal(t, int64(1), names)
+
+	_, err = db.Query(`SELECT toFloat32(price) FROM items`)
+	assert.NotNil(t, err)
+
+	_, err = db.Query(`SELECT missing FROM items`)
+	assert.NotNil(t, err)
+}
+
+func TestDriver_SystemTables(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS legacy (id UUID, validUntil Date) engine=Memory`)
+	assert.Nil(t, err)
+
+	var engine string
+	query := `SELECT engine FROM system.tables WHERE database = currentDatabase() AND name = ?`
+	assert.Nil(t, db.QueryRow(query, "legacy").Scan(&engine))
+	assert.Equal(t, "Memory", engine)
+	assert.Nil(t, db.QueryRow(query, "items").Scan(&engine))
+	assert.Equal(t, "ReplacingMergeTree", engine)
+	assert.Equal(t, sql.ErrNoRows, db.QueryRow(query, "missing").Scan(&engine))
+
+	// Memory tables don't support FINAL
+	_, err = db.Query(`SELECT * FROM legacy FINAL`)
+	assert.NotNil(t, err)
+}
+
+func TestParse(t *testing.T) {
+	_, params, err := parse(`SELECT * FROM items WHERE id = ? AND (name = 'a' OR name != ?)`)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, params)
+
+	for _, query := range []string{
+		`SELECT FROM items`,
+		`DELETE FROM items`,
+		`SELECT * FROM items WHERE name = 'a`,
+		`ALTER TABLE items UPDATE name = 'a' WHERE 1 = 1`,
+		`CREATE TABLE t (id UUID) ENGINE = Log`,
+		`SELECT * FROM system.parts`,
+	} {
+		statement, _, err := parse(query)
+		if err == nil {
+			err = newServer().exec(statement, nil)
+		}
+		assert.NotNil(t, err, query)
+	}
+}
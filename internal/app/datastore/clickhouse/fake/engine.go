@@ -0,0 +1,968 @@
+package fake
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type row map[string]interface{}
+
+// The server keeps a single database, its tables are listed by the system table
+const (
+	database     = "default"
+	systemTables = "system.tables"
+)
+
+type table struct {
+	engine  string
+	columns []columnDef
+	// Version column of ReplacingMergeTree, empty for other engines
+	version     string
+	isReplacing bool
+	sortingKey  []expr
+	indexes     map[string]bool
+	rows        []row
+}
+
+func (t *table) column(name string) (int, bool) {
+	for i, column := range t.columns {
+		if column.name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// server keeps tables of a single database
+type server struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+func newServer() *server {
+	return &server{tables: make(map[string]*table)}
+}
+
+func (s *server) table(name string) (*table, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %s doesn't exist", name)
+	}
+	return t, nil
+}
+
+// exec runs statements which don't return rows, inserts are handled by transactions
+func (s *server) exec(statement interface{}, args []driver.Value) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch statement := statement.(type) {
+	case createTable:
+		return s.createTable(statement)
+	case dropTable:
+		if _, ok := s.tables[statement.name]; !ok && !statement.ifExists {
+			return fmt.Errorf("table %s doesn't exist", statement.name)
+		}
+		delete(s.tables, statement.name)
+		return nil
+	case truncateTable:
+		t, ok := s.tables[statement.name]
+		if !ok {
+			if statement.ifExists {
+				return nil
+			}
+			return fmt.Errorf("table %s doesn't exist", statement.name)
+		}
+		t.rows = nil
+		return nil
+	case alterTable:
+		return s.alterTable(statement, args)
+	case insert:
+		return errors.New("insert statement supported only in the batch mode (use begin/commit)")
+	}
+
+	return fmt.Errorf("%T can't be executed", statement)
+}
+
+func (s *server) createTable(statement createTable) error {
+	if _, ok := s.tables[statement.name]; ok {
+		if statement.ifNotExists {
+			return nil
+		}
+		return fmt.Errorf("table %s already exists", statement.name)
+	}
+
+	t := &table{
+		engine:     statement.engine,
+		columns:    statement.columns,
+		sortingKey: statement.orderBy,
+		indexes:    make(map[string]bool),
+	}
+
+	switch statement.engine {
+	case "Memory":
+		// Memory tables keep rows in insertion order and need no sorting key
+		s.tables[statement.name] = t
+		return nil
+	case "MergeTree":
+	case "ReplacingMergeTree":
+		t.isReplacing = true
+		if len(statement.engineArgs) == 1 {
+			version, ok := statement.engineArgs[0].(ident)
+			if !ok {
+				return fmt.Errorf("unexpected version of %s", statement.name)
+			}
+			t.version = version.name
+		}
+	default:
+		return fmt.Errorf("unsupported engine %s", statement.engine)
+	}
+
+	if len(t.sortingKey) == 0 {
+		return fmt.Errorf("table %s needs ORDER BY", statement.name)
+	}
+
+	s.tables[statement.name] = t
+	return nil
+}
+
+func (s *server) alterTable(statement alterTable, args []driver.Value) error {
+	t, err := s.table(statement.name)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range statement.actions {
+		i, hasColumn := t.column(action.name)
+
+		switch action.kind {
+		case "add column":
+			if hasColumn {
+				if action.conditional {
+					continue
+				}
+				return fmt.Errorf("column %s already exists", action.name)
+			}
+
+			for _, r := range t.rows {
+				value, err := columnValue(action.column, r, args)
+				if err != nil {
+					return err
+				}
+				r[action.name] = value
+			}
+			t.columns = append(t.columns, action.column)
+		case "modify column":
+			if !hasColumn {
+				if action.conditional {
+					continue
+				}
+				return fmt.Errorf("column %s doesn't exist", action.name)
+			}
+
+			converted := make([]interface{}, len(t.rows))
+			for j, r := range t.rows {
+				if converted[j], err = action.column.columnType.cast(r[action.name]); err != nil {
+					return err
+				}
+			}
+			for j, r := range t.rows {
+				r[action.name] = converted[j]
+			}
+			t.columns[i].columnType = action.column.columnType
+		case "drop column":
+			if !hasColumn {
+				if action.conditional {
+					continue
+				}
+				return fmt.Errorf("column %s doesn't exist", action.name)
+			}
+
+			for _, r := range t.rows {
+				delete(r, action.name)
+			}
+			t.columns = append(t.columns[:i], t.columns[i+1:]...)
+		case "add index":
+			if t.indexes[action.name] && !action.conditional {
+				return fmt.Errorf("index %s already exists", action.name)
+			}
+			t.indexes[action.name] = true
+		case "drop index":
+			if !t.indexes[action.name] && !action.conditional {
+				return fmt.Errorf("index %s doesn't exist", action.name)
+			}
+			delete(t.indexes, action.name)
+		}
+	}
+
+	return nil
+}
+
+// columnValue gives default value of the column for a row
+func columnValue(column columnDef, r row, args []driver.Value) (interface{}, error) {
+	if column.defaultValue == nil {
+		return column.columnType.zero(), nil
+	}
+
+	value, err := scope{row: r, args: args}.eval(column.defaultValue)
+	if err != nil {
+		return nil, err
+	}
+	return column.columnType.cast(value)
+}
+
+// newRow converts inserted parameters the way the driver does and fills defaults for the rest
+func (s *server) newRow(statement insert, args []driver.Value) (string, row, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.table(statement.table)
+	if err != nil {
+		return "", nil, err
+	}
+
+	r := make(row, len(t.columns))
+	for i, name := range statement.columns {
+		j, ok := t.column(name)
+		if !ok {
+			return "", nil, fmt.Errorf("no column %s in table %s", name, statement.table)
+		}
+
+		// Parameters of inserts are sent in binary form, so they keep their precision
+		var value driver.Value
+		if p, ok := statement.values[i].(param); ok {
+			value = args[p.index]
+		} else {
+			evaluated, err := scope{args: args}.eval(statement.values[i])
+			if err != nil {
+				return "", nil, err
+			}
+			value = toDriver(evaluated)
+		}
+
+		if r[name], err = t.columns[j].columnType.fromDriver(value); err != nil {
+			return "", nil, fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+
+	for _, column := range t.columns {
+		if _, ok := r[column.name]; ok {
+			continue
+		}
+
+		if r[column.name], err = columnValue(column, r, args); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return statement.table, r, nil
+}
+
+// insert appends a committed block of rows
+func (s *server) insert(block map[string][]row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, rows := range block {
+		t, err := s.table(name)
+		if err != nil {
+			return err
+		}
+		t.rows = append(t.rows, rows...)
+	}
+
+	return nil
+}
+
+// result is a selected set of rows with column order
+type result struct {
+	columns []string
+	rows    []row
+}
+
+// systemTables lists tables of the database the same way as the server does
+func (s *server) systemTables() *result {
+	tables := &result{columns: []string{"database", "name", "engine"}}
+	for name, t := range s.tables {
+		tables.rows = append(tables.rows, row{"database": database, "name": name, "engine": t.engine})
+	}
+
+	return tables
+}
+
+func (s *server) query(statement *selectStatement, args []driver.Value) (*result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.selectRows(statement, args)
+}
+
+func (s *server) selectRows(statement *selectStatement, args []driver.Value) (*result, error) {
+	var source *result
+	if statement.subquery != nil {
+		subquery, err := s.selectRows(statement.subquery, args)
+		if err != nil {
+			return nil, err
+		}
+
+		if statement.final {
+			return nil, errors.New("FINAL is not supported for subqueries")
+		}
+		source = subquery
+	} else if statement.from == systemTables {
+		if statement.final {
+			return nil, fmt.Errorf("storage of %s doesn't support FINAL", statement.from)
+		}
+		source = s.systemTables()
+	} else {
+		t, err := s.table(statement.from)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := t.rows
+		if statement.final {
+			if !t.isReplacing {
+				return nil, fmt.Errorf("storage of %s doesn't support FINAL", statement.from)
+			}
+
+			if rows, err = t.final(args); err != nil {
+				return nil, err
+			}
+		}
+
+		source = &result{rows: rows}
+		for _, column := range t.columns {
+			source.columns = append(source.columns, column.name)
+		}
+	}
+
+	filtered := make([]row, 0, len(source.rows))
+	for _, r := range source.rows {
+		if statement.where != nil {
+			value, err := scope{row: r, args: args}.eval(statement.where)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isTrue(value) {
+				continue
+			}
+		}
+		filtered = append(filtered, r)
+	}
+
+	groups, err := group(statement, filtered, args)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := &result{rows: make([]row, 0, len(groups))}
+	for _, item := range statement.items {
+		if _, ok := item.value.(star); ok {
+			selected.columns = append(selected.columns, source.columns...)
+		} else {
+			selected.columns = append(selected.columns, item.name())
+		}
+	}
+
+	// Sort keys may refer to source columns as well as to aliases
+	sortScopes := make([]scope, 0, len(groups))
+	for _, g := range groups {
+		itemScope := scope{row: g[0], group: g, args: args}
+		out := make(row, len(selected.columns))
+		sortRow := make(row, len(g[0])+len(selected.columns))
+		for name, value := range g[0] {
+			sortRow[name] = value
+		}
+
+		for _, item := range statement.items {
+			if _, ok := item.value.(star); ok {
+				for _, name := range source.columns {
+					out[name] = g[0][name]
+				}
+				continue
+			}
+
+			value, err := itemScope.eval(item.value)
+			if err != nil {
+				return nil, err
+			}
+			out[item.name()] = value
+			sortRow[item.name()] = value
+		}
+
+		selected.rows = append(selected.rows, out)
+		sortScopes = append(sortScopes, scope{row: sortRow, group: g, args: args})
+	}
+
+	if err := sortRows(statement.orderBy, selected.rows, sortScopes); err != nil {
+		return nil, err
+	}
+
+	if statement.limit != nil {
+		value, err := scope{args: args}.eval(statement.limit)
+		if err != nil {
+			return nil, err
+		}
+
+		limit, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if limit < int64(len(selected.rows)) {
+			selected.rows = selected.rows[:limit]
+		}
+	}
+
+	return selected, nil
+}
+
+// final collapses versions of rows with the same sorting key keeping the latest one,
+// the last inserted row wins among equal versions
+func (t *table) final(args []driver.Value) ([]row, error) {
+	latest := make(map[string]int)
+	keys := make([]string, 0)
+	for i, r := range t.rows {
+		key, err := scope{row: r, args: args}.key(t.sortingKey)
+		if err != nil {
+			return nil, err
+		}
+
+		j, ok := latest[key]
+		if !ok {
+			keys = append(keys, key)
+			latest[key] = i
+			continue
+		}
+
+		if t.version != "" {
+			order, err := compare(r[t.version], t.rows[j][t.version])
+			if err != nil {
+				return nil, err
+			}
+
+			if order < 0 {
+				continue
+			}
+		}
+		latest[key] = i
+	}
+
+	rows := make([]row, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, t.rows[latest[key]])
+	}
+	return rows, nil
+}
+
+// group splits rows by GROUP BY keys, every row is a group of its own for queries without aggregation
+func group(statement *selectStatement, rows []row, args []driver.Value) ([][]row, error) {
+	hasAggregates := false
+	for _, item := range statement.items {
+		if isAggregate(item.value) {
+			hasAggregates = true
+		}
+	}
+
+	if len(statement.groupBy) == 0 && !hasAggregates {
+		groups := make([][]row, 0, len(rows))
+		for _, r := range rows {
+			groups = append(groups, []row{r})
+		}
+		return groups, nil
+	}
+
+	// Aggregation over nothing still gives a single row of defaults
+	if len(statement.groupBy) == 0 {
+		if len(rows) == 0 {
+			return [][]row{{row{}}}, nil
+		}
+		return [][]row{rows}, nil
+	}
+
+	indexes := make(map[string]int)
+	groups := make([][]row, 0)
+	for _, r := range rows {
+		key, err := scope{row: r, args: args}.key(statement.groupBy)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+
+	return groups, nil
+}
+
+func sortRows(orderBy []orderItem, rows []row, scopes []scope) error {
+	if len(orderBy) == 0 {
+		return nil
+	}
+
+	keys := make([][]interface{}, len(rows))
+	for i := range rows {
+		keys[i] = make([]interface{}, len(orderBy))
+		for j, item := range orderBy {
+			value, err := scopes[i].eval(item.value)
+			if err != nil {
+				return err
+			}
+			keys[i][j] = value
+		}
+	}
+
+	indexes := make([]int, len(rows))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	var sortErr error
+	sort.SliceStable(indexes, func(a, b int) bool {
+		for j, item := range orderBy {
+			order, err := compare(keys[indexes[a]][j], keys[indexes[b]][j])
+			if err != nil {
+				sortErr = err
+				return false
+			}
+
+			if order == 0 {
+				continue
+			}
+			if item.descending {
+				return order > 0
+			}
+			return order < 0
+		}
+		return false
+	})
+
+	sorted := make([]row, len(rows))
+	for i, j := range indexes {
+		sorted[i] = rows[j]
+	}
+	copy(rows, sorted)
+	return sortErr
+}
+
+func (item selectItem) name() string {
+	if item.alias != "" {
+		return item.alias
+	}
+
+	if column, ok := item.value.(ident); ok {
+		return column.name
+	}
+	return fmt.Sprintf("%v", item.value)
+}
+
+var aggregates = map[string]bool{
+	"sum":    true,
+	"count":  true,
+	"min":    true,
+	"max":    true,
+	"any":    true,
+	"argMin": true,
+	"argMax": true,
+}
+
+func isAggregate(e expr) bool {
+	switch e := e.(type) {
+	case call:
+		if aggregates[e.name] {
+			return true
+		}
+		for _, arg := range e.args {
+			if isAggregate(arg) {
+				return true
+			}
+		}
+	case binary:
+		return isAggregate(e.left) || isAggregate(e.right)
+	case unary:
+		return isAggregate(e.operand)
+	}
+	return false
+}
+
+// scope evaluates expressions against a row, aggregates run over the group of rows
+type scope struct {
+	row   row
+	group []row
+	args  []driver.Value
+}
+
+// key identifies a row by the values of expressions
+func (s scope) key(exprs []expr) (string, error) {
+	parts := make([]string, 0, len(exprs))
+	for _, e := range exprs {
+		value, err := s.eval(e)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%T:%s", value, toString(value)))
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+func (s scope) eval(e expr) (interface{}, error) {
+	switch e := e.(type) {
+	case literal:
+		return e.value, nil
+	case param:
+		if e.index >= len(s.args) {
+			return nil, fmt.Errorf("no value for parameter %d", e.index+1)
+		}
+		return fromArg(s.args[e.index])
+	case ident:
+		value, ok := s.row[e.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown identifier %s", e.name)
+		}
+		return value, nil
+	case unary:
+		operand, err := s.eval(e.operand)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.op == "NOT" {
+			return fromBool(!isTrue(operand)), nil
+		}
+
+		d, err := toDecimal(operand)
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticResult(operand, operand, d.Neg()), nil
+	case binary:
+		return s.binary(e)
+	case call:
+		if aggregates[e.name] {
+			return s.aggregate(e)
+		}
+		return s.function(e)
+	}
+
+	return nil, fmt.Errorf("can't evaluate %v", e)
+}
+
+// in checks membership of the value in the right side of the expression
+func (s scope) in(value interface{}, e binary) (interface{}, error) {
+	items := []expr{e.right}
+	if set, ok := e.right.(tuple); ok {
+		items = set.items
+	}
+
+	found := false
+	for _, item := range items {
+		member, err := s.eval(item)
+		if err != nil {
+			return nil, err
+		}
+
+		order, err := compare(value, member)
+		if err != nil {
+			return nil, err
+		}
+
+		if order == 0 {
+			found = true
+			break
+		}
+	}
+
+	return fromBool(found == (e.op == "IN")), nil
+}
+
+// fromArg converts a parameter to a value, the same way the driver puts it into the query text
+func fromArg(arg driver.Value) (interface{}, error) {
+	switch arg := arg.(type) {
+	case int64, float64, string:
+		return arg, nil
+	case bool:
+		return fromBool(arg), nil
+	case []byte:
+		return string(arg), nil
+	case time.Time:
+		// Driver formats time parameters with seconds precision
+		return arg.Truncate(time.Second).UTC(), nil
+	case nil:
+		return nil, errors.New("NULL parameters are not supported")
+	}
+
+	return nil, fmt.Errorf("unsupported parameter %T", arg)
+}
+
+func (s scope) binary(e binary) (interface{}, error) {
+	left, err := s.eval(e.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit keeps type errors of the skipped branch away, like the server does
+	switch e.op {
+	case "AND":
+		if !isTrue(left) {
+			return int64(0), nil
+		}
+	case "OR":
+		if isTrue(left) {
+			return int64(1), nil
+		}
+	}
+
+	if e.op == "IN" || e.op == "NOT IN" {
+		return s.in(left, e)
+	}
+
+	right, err := s.eval(e.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "AND", "OR":
+		return fromBool(isTrue(right)), nil
+	case "+", "-", "*", "/":
+		leftDecimal, err := toDecimal(left)
+		if err != nil {
+			return nil, err
+		}
+		rightDecimal, err := toDecimal(right)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.op {
+		case "+":
+			return arithmeticResult(left, right, leftDecimal.Add(rightDecimal)), nil
+		case "-":
+			return arithmeticResult(left, right, leftDecimal.Sub(rightDecimal)), nil
+		case "*":
+			return arithmeticResult(left, right, leftDecimal.Mul(rightDecimal)), nil
+		}
+
+		if rightDecimal.IsZero() {
+			return nil, errors.New("division by zero")
+		}
+		quotient, _ := leftDecimal.Div(rightDecimal).Float64()
+		return quotient, nil
+	}
+
+	order, err := compare(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "=":
+		return fromBool(order == 0), nil
+	case "!=":
+		return fromBool(order != 0), nil
+	case "<":
+		return fromBool(order < 0), nil
+	case "<=":
+		return fromBool(order <= 0), nil
+	case ">":
+		return fromBool(order > 0), nil
+	case ">=":
+		return fromBool(order >= 0), nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %s", e.op)
+}
+
+// arithmeticResult keeps decimals decimal and integers integer
+func arithmeticResult(left, right interface{}, value decimal.Decimal) interface{} {
+	for _, operand := range []interface{}{left, right} {
+		if operand, ok := operand.(decimalValue); ok {
+			return newDecimalValue(value, operand.scale)
+		}
+	}
+
+	for _, operand := range []interface{}{left, right} {
+		if _, ok := operand.(float64); ok {
+			f, _ := value.Float64()
+			return f
+		}
+	}
+
+	return value.IntPart()
+}
+
+func (s scope) function(e call) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		value, err := s.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	expectArgs := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s expects %d arguments, got %d", e.name, n, len(args))
+		}
+		return nil
+	}
+
+	switch e.name {
+	case "toInt64", "toUInt64", "toUInt32", "toUInt8":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		return toInt64(args[0])
+	case "currentDatabase":
+		if err := expectArgs(0); err != nil {
+			return nil, err
+		}
+		return database, nil
+	case "toString":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+		return toString(args[0]), nil
+	case "toDecimal64", "toDecimal32":
+		if err := expectArgs(2); err != nil {
+			return nil, err
+		}
+
+		scale, err := toInt64(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return columnType{name: "Decimal64", scale: int32(scale)}.cast(args[0])
+	case "fromUnixTimestamp64Nano":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+
+		nanoseconds, err := toInt64(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, nanoseconds).UTC(), nil
+	case "toYYYYMM":
+		if err := expectArgs(1); err != nil {
+			return nil, err
+		}
+
+		t, ok := args[0].(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("toYYYYMM expects time, got %T", args[0])
+		}
+		return int64(t.Year()*100 + int(t.Month())), nil
+	case "if":
+		if err := expectArgs(3); err != nil {
+			return nil, err
+		}
+
+		if isTrue(args[0]) {
+			return args[1], nil
+		}
+		return args[2], nil
+	case "greatest", "least":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s expects arguments", e.name)
+		}
+
+		value := args[0]
+		for _, arg := range args[1:] {
+			order, err := compare(arg, value)
+			if err != nil {
+				return nil, err
+			}
+
+			if (e.name == "greatest" && order > 0) || (e.name == "least" && order < 0) {
+				value = arg
+			}
+		}
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("unknown function %s", e.name)
+}
+
+func (s scope) aggregate(e call) (interface{}, error) {
+	if s.group == nil {
+		return nil, fmt.Errorf("aggregate function %s is not allowed here", e.name)
+	}
+
+	// Group of an empty set consists of a single empty row
+	rows := s.group
+	if len(rows) == 1 && len(rows[0]) == 0 {
+		rows = nil
+	}
+
+	if e.name == "count" {
+		return int64(len(rows)), nil
+	}
+
+	expectedArgs := 1
+	if strings.HasPrefix(e.name, "arg") {
+		expectedArgs = 2
+	}
+	if len(e.args) != expectedArgs {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", e.name, expectedArgs, len(e.args))
+	}
+
+	var result, best interface{}
+	for i, r := range rows {
+		rowScope := scope{row: r, args: s.args}
+		value, err := rowScope.eval(e.args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		criterion := value
+		if expectedArgs == 2 {
+			if criterion, err = rowScope.eval(e.args[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		if i == 0 {
+			result, best = value, criterion
+			continue
+		}
+
+		switch e.name {
+		case "sum":
+			sum, err := s.binary(binary{op: "+", left: literal{result}, right: literal{value}})
+			if err != nil {
+				return nil, err
+			}
+			result = sum
+		case "min", "max", "argMin", "argMax":
+			order, err := compare(criterion, best)
+			if err != nil {
+				return nil, err
+			}
+
+			if (strings.HasSuffix(e.name, "ax") && order > 0) || (strings.HasSuffix(e.name, "in") && order < 0) {
+				result, best = value, criterion
+			}
+		}
+	}
+
+	// Empty set gives default values instead of NULL
+	if result == nil {
+		return int64(0), nil
+	}
+	return result, nil
+}
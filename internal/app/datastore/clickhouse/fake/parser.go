@@ -0,0 +1,859 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenSymbol
+	tokenParam
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// Position of the parameter in the statement
+	param int
+}
+
+// tokenize splits a statement into tokens numbering parameters on the way
+func tokenize(query string) ([]token, int, error) {
+	tokens := make([]token, 0)
+	params := 0
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r) || r == ';':
+			i++
+		case r == '?':
+			tokens = append(tokens, token{kind: tokenParam, text: "?", param: params})
+			params++
+			i++
+		case r == '\'':
+			var text strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, 0, fmt.Errorf("unterminated string in %q", query)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text.String()})
+			i++
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i])})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i])})
+		default:
+			symbol := string(r)
+			if i+1 < len(runes) {
+				switch pair := string(runes[i : i+2]); pair {
+				case "<=", ">=", "!=", "<>":
+					symbol = pair
+				}
+			}
+			if !strings.Contains("(),=<>!*+-/.", string(r)) {
+				return nil, 0, fmt.Errorf("unexpected %q in %q", r, query)
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol})
+			i += len(symbol)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), params, nil
+}
+
+// Expressions
+type (
+	literal struct{ value interface{} }
+	param   struct{ index int }
+	ident   struct{ name string }
+	call    struct {
+		name string
+		args []expr
+	}
+	binary struct {
+		op          string
+		left, right expr
+	}
+	unary struct {
+		op      string
+		operand expr
+	}
+	tuple struct{ items []expr }
+	star  struct{}
+)
+
+type expr interface{}
+
+// Statements
+type (
+	columnDef struct {
+		name         string
+		columnType   columnType
+		defaultValue expr
+	}
+	createTable struct {
+		name        string
+		ifNotExists bool
+		columns     []columnDef
+		engine      string
+		engineArgs  []expr
+		orderBy     []expr
+	}
+	dropTable struct {
+		name     string
+		ifExists bool
+	}
+	truncateTable struct {
+		name     string
+		ifExists bool
+	}
+	alterAction struct {
+		kind string
+		// Column or index name
+		name   string
+		column columnDef
+		// IF EXISTS or IF NOT EXISTS was given
+		conditional bool
+	}
+	alterTable struct {
+		name    string
+		actions []alterAction
+	}
+	insert struct {
+		table   string
+		columns []string
+		values  []expr
+	}
+	selectItem struct {
+		value expr
+		alias string
+	}
+	orderItem struct {
+		value      expr
+		descending bool
+	}
+	selectStatement struct {
+		items    []selectItem
+		from     string
+		subquery *selectStatement
+		final    bool
+		where    expr
+		groupBy  []expr
+		orderBy  []orderItem
+		limit    expr
+	}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse reads a single statement and returns it along with its number of parameters
+func parse(query string) (interface{}, int, error) {
+	tokens, params, err := tokenize(query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p := &parser{tokens: tokens}
+	statement, err := p.statement()
+	if err != nil {
+		return nil, 0, fmt.Errorf("%v in %q", err, strings.Join(strings.Fields(query), " "))
+	}
+
+	if p.peek().kind != tokenEOF {
+		return nil, 0, fmt.Errorf("unexpected %q in %q", p.peek().text, strings.Join(strings.Fields(query), " "))
+	}
+
+	return statement, params, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// isKeyword checks the next token without consuming it
+func (p *parser) isKeyword(keywords ...string) bool {
+	for i, keyword := range keywords {
+		if p.pos+i >= len(p.tokens) {
+			return false
+		}
+
+		t := p.tokens[p.pos+i]
+		if t.kind != tokenIdent || !strings.EqualFold(t.text, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyword consumes the keywords if they are next
+func (p *parser) keyword(keywords ...string) bool {
+	if !p.isKeyword(keywords...) {
+		return false
+	}
+
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *parser) expectKeyword(keywords ...string) error {
+	if !p.keyword(keywords...) {
+		return fmt.Errorf("expected %s, got %q", strings.Join(keywords, " "), p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) isSymbol(symbol string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.text == symbol
+}
+
+func (p *parser) symbol(symbol string) bool {
+	if !p.isSymbol(symbol) {
+		return false
+	}
+
+	p.pos++
+	return true
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.symbol(symbol) {
+		return fmt.Errorf("expected %q, got %q", symbol, p.peek().text)
+	}
+	return nil
+}
+
+// name reads a table or column name, table may be qualified by its database
+func (p *parser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return "", fmt.Errorf("expected name, got %q", t.text)
+	}
+
+	if p.symbol(".") {
+		qualified := p.next()
+		if qualified.kind != tokenIdent {
+			return "", fmt.Errorf("expected name, got %q", qualified.text)
+		}
+		return t.text + "." + qualified.text, nil
+	}
+	return t.text, nil
+}
+
+func (p *parser) statement() (interface{}, error) {
+	switch {
+	case p.keyword("CREATE", "TABLE"):
+		return p.createTable()
+	case p.keyword("DROP", "TABLE"):
+		statement := dropTable{ifExists: p.keyword("IF", "EXISTS")}
+		name, err := p.name()
+		statement.name = name
+		return statement, err
+	case p.keyword("TRUNCATE", "TABLE"):
+		statement := truncateTable{ifExists: p.keyword("IF", "EXISTS")}
+		name, err := p.name()
+		statement.name = name
+		return statement, err
+	case p.keyword("ALTER", "TABLE"):
+		return p.alterTable()
+	case p.keyword("INSERT", "INTO"):
+		return p.insert()
+	case p.isKeyword("SELECT"):
+		return p.selectStatement()
+	}
+
+	return nil, fmt.Errorf("unsupported statement %q", p.peek().text)
+}
+
+func (p *parser) createTable() (interface{}, error) {
+	statement := createTable{ifNotExists: p.keyword("IF", "NOT", "EXISTS")}
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	statement.name = name
+
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		column, err := p.columnDef()
+		if err != nil {
+			return nil, err
+		}
+		statement.columns = append(statement.columns, column)
+
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	if err := p.expectKeyword("ENGINE"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("="); err != nil {
+		return nil, err
+	}
+
+	engine, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch engine := engine.(type) {
+	case ident:
+		statement.engine = engine.name
+	case call:
+		statement.engine = engine.name
+		statement.engineArgs = engine.args
+	default:
+		return nil, fmt.Errorf("unexpected engine %v", engine)
+	}
+
+	for p.peek().kind != tokenEOF {
+		switch {
+		case p.keyword("PARTITION", "BY"), p.keyword("PRIMARY", "KEY"):
+			if _, err := p.expression(); err != nil {
+				return nil, err
+			}
+		case p.keyword("ORDER", "BY"):
+			orderBy, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			if key, ok := orderBy.(tuple); ok {
+				statement.orderBy = key.items
+			} else {
+				statement.orderBy = []expr{orderBy}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported table clause %q", p.peek().text)
+		}
+	}
+
+	return statement, nil
+}
+
+func (p *parser) columnDef() (columnDef, error) {
+	name, err := p.name()
+	if err != nil {
+		return columnDef{}, err
+	}
+
+	typeExpr, err := p.primary()
+	if err != nil {
+		return columnDef{}, err
+	}
+
+	columnType, err := newColumnType(typeExpr)
+	if err != nil {
+		return columnDef{}, err
+	}
+
+	column := columnDef{name: name, columnType: columnType}
+	if p.keyword("DEFAULT") {
+		if column.defaultValue, err = p.expression(); err != nil {
+			return columnDef{}, err
+		}
+	}
+
+	return column, nil
+}
+
+func (p *parser) alterTable() (interface{}, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	statement := alterTable{name: name}
+	for {
+		action, err := p.alterAction()
+		if err != nil {
+			return nil, err
+		}
+		statement.actions = append(statement.actions, action)
+
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	return statement, nil
+}
+
+func (p *parser) alterAction() (alterAction, error) {
+	var action alterAction
+
+	switch {
+	case p.keyword("ADD", "COLUMN"):
+		action.kind = "add column"
+		action.conditional = p.keyword("IF", "NOT", "EXISTS")
+		column, err := p.columnDef()
+		if err != nil {
+			return action, err
+		}
+		action.name, action.column = column.name, column
+
+		if p.keyword("AFTER") {
+			if _, err := p.name(); err != nil {
+				return action, err
+			}
+		}
+	case p.keyword("MODIFY", "COLUMN"):
+		action.kind = "modify column"
+		action.conditional = p.keyword("IF", "EXISTS")
+		column, err := p.columnDef()
+		if err != nil {
+			return action, err
+		}
+		action.name, action.column = column.name, column
+	case p.keyword("DROP", "COLUMN"):
+		action.kind = "drop column"
+		action.conditional = p.keyword("IF", "EXISTS")
+		name, err := p.name()
+		if err != nil {
+			return action, err
+		}
+		action.name = name
+	case p.keyword("ADD", "INDEX"):
+		action.kind = "add index"
+		action.conditional = p.keyword("IF", "NOT", "EXISTS")
+		name, err := p.name()
+		if err != nil {
+			return action, err
+		}
+		action.name = name
+
+		// Skipping indexes don't change results, so only the index name is kept
+		if _, err := p.expression(); err != nil {
+			return action, err
+		}
+		if err := p.expectKeyword("TYPE"); err != nil {
+			return action, err
+		}
+		if _, err := p.primary(); err != nil {
+			return action, err
+		}
+		if p.keyword("GRANULARITY") {
+			if _, err := p.primary(); err != nil {
+				return action, err
+			}
+		}
+	case p.keyword("DROP", "INDEX"):
+		action.kind = "drop index"
+		action.conditional = p.keyword("IF", "EXISTS")
+		name, err := p.name()
+		if err != nil {
+			return action, err
+		}
+		action.name = name
+	default:
+		return action, fmt.Errorf("unsupported alter action %q", p.peek().text)
+	}
+
+	return action, nil
+}
+
+func (p *parser) insert() (interface{}, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+
+	statement := insert{table: name}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		column, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		statement.columns = append(statement.columns, column)
+
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		statement.values = append(statement.values, value)
+
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+
+	if len(statement.values) != len(statement.columns) {
+		return nil, fmt.Errorf("%d values for %d columns", len(statement.values), len(statement.columns))
+	}
+
+	return statement, nil
+}
+
+func (p *parser) selectStatement() (*selectStatement, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	statement := &selectStatement{}
+	for {
+		var item selectItem
+		if p.symbol("*") {
+			item.value = star{}
+		} else {
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			item.value = value
+
+			if p.keyword("AS") {
+				if item.alias, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		statement.items = append(statement.items, item)
+
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+
+	if p.symbol("(") {
+		subquery, err := p.selectStatement()
+		if err != nil {
+			return nil, err
+		}
+		statement.subquery = subquery
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		statement.from = name
+	}
+
+	statement.final = p.keyword("FINAL")
+
+	var err error
+	if p.keyword("WHERE") {
+		if statement.where, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.keyword("GROUP", "BY") {
+		for {
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			statement.groupBy = append(statement.groupBy, value)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("ORDER", "BY") {
+		for {
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			item := orderItem{value: value}
+			if p.keyword("DESC") {
+				item.descending = true
+			} else {
+				p.keyword("ASC")
+			}
+			statement.orderBy = append(statement.orderBy, item)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		if statement.limit, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+
+	return statement, nil
+}
+
+func (p *parser) expression() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "OR", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: "AND", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.keyword("NOT") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "NOT", operand: operand}, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+
+	// Set membership takes a tuple or a single value on the right
+	for _, op := range [][]string{{"IN"}, {"NOT", "IN"}} {
+		if p.keyword(op...) {
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			return binary{op: strings.Join(op, " "), left: left, right: right}, nil
+		}
+	}
+
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.symbol(op) {
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+
+			if op == "<>" {
+				op = "!="
+			}
+			return binary{op: op, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isSymbol("+") || p.isSymbol("-") {
+		op := p.next().text
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isSymbol("*") || p.isSymbol("/") {
+		op := p.next().text
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.symbol("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "-", operand: operand}, nil
+	}
+
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if strings.Contains(t.text, ".") {
+			value, err := strconv.ParseFloat(t.text, 64)
+			return literal{value: value}, err
+		}
+
+		value, err := strconv.ParseInt(t.text, 10, 64)
+		return literal{value: value}, err
+	case tokenString:
+		return literal{value: t.text}, nil
+	case tokenParam:
+		return param{index: t.param}, nil
+	case tokenSymbol:
+		if t.text != "(" {
+			break
+		}
+
+		items := make([]expr, 0)
+		for !p.isSymbol(")") {
+			item, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return tuple{items: items}, nil
+	case tokenIdent:
+		if !p.symbol("(") {
+			return ident{name: t.text}, nil
+		}
+
+		function := call{name: t.text, args: make([]expr, 0)}
+		for !p.isSymbol(")") {
+			if p.symbol("*") {
+				function.args = append(function.args, star{})
+			} else {
+				arg, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				function.args = append(function.args, arg)
+			}
+
+			if !p.symbol(",") {
+				break
+			}
+		}
+
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		return function, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,307 @@
+package fake
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"time"
+)
+
+// Values are kept as int64, float64, string, time.Time or decimalValue,
+// the same way for columns and computed expressions
+
+// decimalValue remembers its scale, since the driver reads decimals as unscaled integers
+type decimalValue struct {
+	value decimal.Decimal
+	scale int32
+}
+
+func newDecimalValue(value decimal.Decimal, scale int32) decimalValue {
+	return decimalValue{value: value.Truncate(scale), scale: scale}
+}
+
+// columnType is a supported subset of clickhouse types
+type columnType struct {
+	name  string
+	scale int32
+}
+
+func newColumnType(typeExpr expr) (columnType, error) {
+	switch typeExpr := typeExpr.(type) {
+	case ident:
+		switch typeExpr.name {
+		case "UInt8", "UInt16", "UInt32", "UInt64", "Int8", "Int16", "Int32", "Int64",
+			"Float32", "Float64", "String", "UUID", "Date", "DateTime", "DateTime64":
+			return columnType{name: typeExpr.name}, nil
+		}
+	case call:
+		switch typeExpr.name {
+		case "DateTime", "DateTime64":
+			// Precision and timezone don't matter, since times are kept in UTC with nanoseconds
+			return columnType{name: typeExpr.name}, nil
+		case "Decimal32", "Decimal64":
+			if len(typeExpr.args) != 1 {
+				break
+			}
+
+			if scale, ok := typeExpr.args[0].(literal); ok {
+				if scale, ok := scale.value.(int64); ok {
+					return columnType{name: typeExpr.name, scale: int32(scale)}, nil
+				}
+			}
+		}
+	}
+
+	return columnType{}, fmt.Errorf("unsupported type %v", typeExpr)
+}
+
+func (t columnType) isInteger() bool {
+	switch t.name {
+	case "UInt8", "UInt16", "UInt32", "UInt64", "Int8", "Int16", "Int32", "Int64":
+		return true
+	}
+	return false
+}
+
+func (t columnType) isDecimal() bool {
+	return t.name == "Decimal32" || t.name == "Decimal64"
+}
+
+// zero is the value of a column which wasn't given
+func (t columnType) zero() interface{} {
+	switch {
+	case t.isInteger():
+		return int64(0)
+	case t.isDecimal():
+		return newDecimalValue(decimal.Zero, t.scale)
+	}
+
+	switch t.name {
+	case "Float32", "Float64":
+		return float64(0)
+	case "UUID":
+		return uuid.Nil.String()
+	case "Date", "DateTime", "DateTime64":
+		return time.Unix(0, 0).UTC()
+	}
+	return ""
+}
+
+// fromDriver converts an inserted parameter the same way as the driver does,
+// notably decimals are written as unscaled integers
+func (t columnType) fromDriver(value driver.Value) (interface{}, error) {
+	if value == nil {
+		return t.zero(), nil
+	}
+
+	switch {
+	case t.isInteger():
+		switch value := value.(type) {
+		case int64:
+			return value, nil
+		case bool:
+			if value {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+	case t.isDecimal():
+		switch value := value.(type) {
+		case int64:
+			return newDecimalValue(decimal.New(value, -t.scale), t.scale), nil
+		case float64:
+			return newDecimalValue(decimal.NewFromFloat(value), t.scale), nil
+		}
+	}
+
+	switch t.name {
+	case "Float32", "Float64":
+		switch value := value.(type) {
+		case float64:
+			return value, nil
+		case int64:
+			return float64(value), nil
+		}
+	case "String":
+		switch value := value.(type) {
+		case string:
+			return value, nil
+		case []byte:
+			return string(value), nil
+		}
+	case "UUID":
+		switch value := value.(type) {
+		case string:
+			id, err := uuid.Parse(value)
+			return id.String(), err
+		case []byte:
+			id, err := uuid.FromBytes(value)
+			return id.String(), err
+		}
+	case "Date":
+		if value, ok := value.(time.Time); ok {
+			return value.UTC().Truncate(24 * time.Hour), nil
+		}
+	case "DateTime":
+		if value, ok := value.(time.Time); ok {
+			return value.Truncate(time.Second).UTC(), nil
+		}
+	case "DateTime64":
+		if value, ok := value.(time.Time); ok {
+			return value.UTC(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %T value for %s column", value, t.name)
+}
+
+// cast converts a stored value the way the server does on column type change
+func (t columnType) cast(value interface{}) (interface{}, error) {
+	switch {
+	case t.isInteger():
+		return toInt64(value)
+	case t.isDecimal():
+		d, err := toDecimal(value)
+		return newDecimalValue(d, t.scale), err
+	}
+
+	switch t.name {
+	case "Float32", "Float64":
+		d, err := toDecimal(value)
+		f, _ := d.Float64()
+		return f, err
+	case "String", "UUID":
+		return toString(value), nil
+	case "Date":
+		if value, ok := value.(time.Time); ok {
+			return value.UTC().Truncate(24 * time.Hour), nil
+		}
+	case "DateTime":
+		if value, ok := value.(time.Time); ok {
+			return value.Truncate(time.Second), nil
+		}
+	case "DateTime64":
+		if value, ok := value.(time.Time); ok {
+			return value, nil
+		}
+	}
+
+	return nil, fmt.Errorf("can't cast %T to %s", value, t.name)
+}
+
+// toDriver gives the value the same way as the driver reads it
+func toDriver(value interface{}) driver.Value {
+	if value, ok := value.(decimalValue); ok {
+		return value.value.Shift(value.scale).IntPart()
+	}
+	return value
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch value := value.(type) {
+	case int64:
+		return value, nil
+	case float64:
+		return int64(value), nil
+	case decimalValue:
+		return value.value.IntPart(), nil
+	case string:
+		return strconv.ParseInt(value, 10, 64)
+	case time.Time:
+		return value.Unix(), nil
+	}
+
+	return 0, fmt.Errorf("can't convert %T to integer", value)
+}
+
+func toDecimal(value interface{}) (decimal.Decimal, error) {
+	switch value := value.(type) {
+	case int64:
+		return decimal.NewFromInt(value), nil
+	case float64:
+		return decimal.NewFromFloat(value), nil
+	case decimalValue:
+		return value.value, nil
+	case string:
+		return decimal.NewFromString(value)
+	}
+
+	return decimal.Zero, fmt.Errorf("can't convert %T to decimal", value)
+}
+
+func toString(value interface{}) string {
+	switch value := value.(type) {
+	case decimalValue:
+		return value.value.StringFixed(value.scale)
+	case time.Time:
+		return value.Format("2006-01-02 15:04:05.999999999")
+	}
+
+	return fmt.Sprint(value)
+}
+
+func isTrue(value interface{}) bool {
+	number, err := toInt64(value)
+	return err == nil && number != 0
+}
+
+func fromBool(value bool) int64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// compare orders values of the same family, numbers of different types are compared exactly
+func compare(left, right interface{}) (int, error) {
+	switch left := left.(type) {
+	case string:
+		if right, ok := right.(string); ok {
+			switch {
+			case left < right:
+				return -1, nil
+			case left > right:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case time.Time:
+		if right, ok := right.(time.Time); ok {
+			switch {
+			case left.Before(right):
+				return -1, nil
+			case left.After(right):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case int64:
+		if right, ok := right.(int64); ok {
+			switch {
+			case left < right:
+				return -1, nil
+			case left > right:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	if isNumber(left) && isNumber(right) {
+		leftDecimal, _ := toDecimal(left)
+		rightDecimal, _ := toDecimal(right)
+		return leftDecimal.Cmp(rightDecimal), nil
+	}
+
+	return 0, fmt.Errorf("can't compare %T with %T", left, right)
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64, decimalValue:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+const instrumentColumns = `tradeCode, symbol, tickSize, lotSize, minQuantity, maxQuantity, priceScale, currency, status`
+
+// SaveInstrument creates or replaces reference data of the instrument with a newer version of it
+func (o *OrderBook) SaveInstrument(ctx context.Context, instrument *models.Instrument) error {
+	if err := datastore.ValidateInstrument(instrument); err != nil {
+		return err
+	}
+
+	if instrument.PriceScale > priceScale {
+		return datastore.ErrPriceScale
+	}
+
+	return o.insertBlock(ctx, o.sql(`
+		INSERT INTO {instruments}
+			(`+instrumentColumns+`, version)
+			VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), [][]interface{}{{
+		instrument.TradeCode,
+		instrument.Symbol,
+		priceToStorage(instrument.TickSize),
+		uint32(instrument.LotSize),
+		uint32(instrument.MinQuantity),
+		uint32(instrument.MaxQuantity),
+		uint8(instrument.PriceScale),
+		instrument.Currency,
+		instrument.Status,
+		o.nextSequence(time.Now().UTC()),
+	}})
+}
+
+// InstrumentByTradeCode returns the latest reference data of the instrument
+func (o *OrderBook) InstrumentByTradeCode(ctx context.Context, tradeCode uuid.UUID) (*models.Instrument, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	instrument, err := o.instrument(ctx, tradeCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if instrument == nil {
+		return nil, datastore.ErrInstrumentDoesNotExist
+	}
+
+	return instrument, nil
+}
+
+// instrument returns reference data of the instrument or nil when there is none
+func (o *OrderBook) instrument(ctx context.Context, tradeCode uuid.UUID) (*models.Instrument, error) {
+	stmt, err := o.stmt(ctx, o.sql(`SELECT `+instrumentColumns+` FROM {instruments} FINAL WHERE tradeCode = ?`))
+	if err != nil {
+		return nil, err
+	}
+
+	instrument := new(models.Instrument)
+	if err := stmt.GetContext(ctx, instrument, tradeCode); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	instrument.TickSize = priceFromStorage(instrument.TickSize)
+	return instrument, nil
+}
+
+// checkInstrument checks order prices and quantity against reference data of its instrument
+func (o *OrderBook) checkInstrument(ctx context.Context, order *models.Order) error {
+	instrument, err := o.instrument(ctx, order.TradeCode)
+	if err != nil {
+		return err
+	}
+
+	return datastore.ValidateOrderForInstrument(instrument, order)
+}
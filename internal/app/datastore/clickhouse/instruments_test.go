@@ -0,0 +1,37 @@
+package clickhouse
+
+import (
+	"context"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrderBook_SaveInstrument(t *testing.T) {
+	db, teardown := testDB(t)
+	defer teardown()
+
+	instrument := &models.Instrument{
+		TradeCode:  uuid.New(),
+		Symbol:     "TEST",
+		TickSize:   decimal.RequireFromString("0.000000001"),
+		LotSize:    1,
+		PriceScale: 9,
+		Status:     models.Active,
+	}
+
+	// Prices can't be more precise than the storage
+	assert.Equal(t, datastore.ErrPriceScale, db.SaveInstrument(context.Background(), instrument))
+
+	instrument.TickSize = decimal.RequireFromString("0.00000001")
+	instrument.PriceScale = priceScale
+	assert.Nil(t, db.SaveInstrument(context.Background(), instrument))
+
+	instrumentFromStore, err := db.InstrumentByTradeCode(context.Background(), instrument.TradeCode)
+	assert.Nil(t, err)
+	assert.True(t, instrument.TickSize.Equal(instrumentFromStore.TickSize))
+	assert.Equal(t, priceScale, instrumentFromStore.PriceScale)
+}
@@ -0,0 +1,274 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// migration is a versioned schema change, statements may refer to configured tables by placeholders
+type migration struct {
+	version uint32
+	name    string
+	// prepare runs before statements applying the migration, for changes which depend on the current schema
+	prepare func(o *OrderBook, ctx context.Context) error
+	up      []string
+	down    []string
+}
+
+// Migrations are applied in the order of versions and never change once released
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_orders",
+		prepare: (*OrderBook).dropLegacyOrders,
+		up: []string{`
+			CREATE TABLE IF NOT EXISTS {orders} (
+				id UUID,
+				tradeCode UUID,
+				validUntil DateTime,
+				price String,
+				quantity UInt32,
+				operation String,
+				counterParty String,
+				isEnabled UInt8,
+				createdAt DateTime64(9, 'UTC'),
+				sequence UInt64,
+				originalQuantity UInt32,
+				filledQuantity UInt32,
+				averageFillPrice String,
+				status String,
+				type String,
+				version UInt64
+			)
+			ENGINE = ReplacingMergeTree(version)
+			ORDER BY (tradeCode, operation, id)
+		`},
+		down: []string{`DROP TABLE IF EXISTS {orders}`},
+	},
+	{
+		version: 2,
+		name:    "create_trades",
+		up: []string{`
+			CREATE TABLE IF NOT EXISTS {trades} (
+				id UUID,
+				buyOrderID UUID,
+				sellOrderID UUID,
+				buyCounterParty String,
+				sellCounterParty String,
+				tradeCode UUID,
+				price String,
+				quantity UInt32,
+				executedAt DateTime64(9, 'UTC'),
+				aggressor String
+			)
+			ENGINE = MergeTree()
+			PARTITION BY toYYYYMM(executedAt)
+			ORDER BY (tradeCode, executedAt)
+		`},
+		down: []string{`DROP TABLE IF EXISTS {trades}`},
+	},
+	{
+		version: 3,
+		name:    "orders_valid_until_precision",
+		up:      []string{`ALTER TABLE {orders} MODIFY COLUMN validUntil DateTime64(9, 'UTC')`},
+		down:    []string{`ALTER TABLE {orders} MODIFY COLUMN validUntil DateTime`},
+	},
+	{
+		// Average fill price stays a string, since it is a quotient which needs more places than the storage scale
+		version: 4,
+		name:    "decimal_prices",
+		up: []string{
+			`ALTER TABLE {orders} MODIFY COLUMN price Decimal64(8)`,
+			`ALTER TABLE {orders} ADD INDEX IF NOT EXISTS price_minmax price TYPE minmax GRANULARITY 4`,
+			`ALTER TABLE {trades} MODIFY COLUMN price Decimal64(8)`,
+		},
+		down: []string{
+			`ALTER TABLE {trades} MODIFY COLUMN price String`,
+			`ALTER TABLE {orders} DROP INDEX IF EXISTS price_minmax`,
+			`ALTER TABLE {orders} MODIFY COLUMN price String`,
+		},
+	},
+	{
+		version: 5,
+		name:    "create_instruments",
+		up: []string{`
+			CREATE TABLE IF NOT EXISTS {instruments} (
+				tradeCode UUID,
+				symbol String,
+				tickSize Decimal64(8),
+				lotSize UInt32,
+				minQuantity UInt32,
+				maxQuantity UInt32,
+				priceScale UInt8,
+				currency String,
+				status String,
+				version UInt64
+			)
+			ENGINE = ReplacingMergeTree(version)
+			ORDER BY tradeCode
+		`},
+		down: []string{`DROP TABLE IF EXISTS {instruments}`},
+	},
+	{
+		// Stop orders are stored along with the others and told apart by their type
+		version: 6,
+		name:    "stop_orders",
+		up:      []string{`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS stopPrice Decimal64(8)`},
+		down:    []string{`ALTER TABLE {orders} DROP COLUMN IF EXISTS stopPrice`},
+	},
+	{
+		// Peak left to fill is stored, since it can't be derived from the quantity left
+		version: 7,
+		name:    "iceberg_orders",
+		up: []string{
+			`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS displayQuantity UInt32`,
+			`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS peakQuantity UInt32`,
+		},
+		down: []string{
+			`ALTER TABLE {orders} DROP COLUMN IF EXISTS peakQuantity`,
+			`ALTER TABLE {orders} DROP COLUMN IF EXISTS displayQuantity`,
+		},
+	},
+	{
+		version: 8,
+		name:    "post_only_hidden_orders",
+		up: []string{
+			`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS postOnly String`,
+			`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS isHidden UInt8`,
+		},
+		down: []string{
+			`ALTER TABLE {orders} DROP COLUMN IF EXISTS isHidden`,
+			`ALTER TABLE {orders} DROP COLUMN IF EXISTS postOnly`,
+		},
+	},
+	{
+		// Empty kind of orders stored before is a limit order
+		version: 9,
+		name:    "order_kinds",
+		up:      []string{`ALTER TABLE {orders} ADD COLUMN IF NOT EXISTS kind String`},
+		down:    []string{`ALTER TABLE {orders} DROP COLUMN IF EXISTS kind`},
+	},
+}
+
+// dropLegacyOrders drops orders table of memory engine, which the order book used to create before migrations.
+// The table has none of the columns the order book needs, and memory engine keeps no rows across server restarts
+func (o *OrderBook) dropLegacyOrders(ctx context.Context) error {
+	var engine string
+	err := o.db.GetContext(ctx, &engine, `
+		SELECT engine
+		FROM system.tables
+		WHERE database = currentDatabase() AND name = ?
+	`, o.config.OrdersTable)
+	if err == sql.ErrNoRows || (err == nil && engine != "Memory") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.ExecContext(ctx, o.sql(`DROP TABLE {orders}`))
+	return err
+}
+
+// createMigrationsTable keeps history of applied migrations,
+// rollback inserts a newer row with isApplied = 0 instead of deleting
+func (o *OrderBook) createMigrationsTable(ctx context.Context) error {
+	_, err := o.db.ExecContext(ctx, o.sql(`
+		CREATE TABLE IF NOT EXISTS {migrations} (
+			version UInt32,
+			name String,
+			isApplied UInt8,
+			changedAt DateTime64(9, 'UTC')
+		)
+		ENGINE = ReplacingMergeTree(changedAt)
+		ORDER BY version
+	`))
+	return err
+}
+
+// SchemaVersion returns the latest applied migration version, zero for an empty schema
+func (o *OrderBook) SchemaVersion(ctx context.Context) (uint32, error) {
+	if err := o.createMigrationsTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version uint32
+	err := o.db.GetContext(ctx, &version, o.sql(`
+		SELECT max(version)
+		FROM (
+			SELECT version, argMax(isApplied, changedAt) AS applied
+			FROM {migrations}
+			GROUP BY version
+		)
+		WHERE applied = 1
+	`))
+	return version, err
+}
+
+// Migrate applies all pending migrations
+func (o *OrderBook) Migrate(ctx context.Context) error {
+	current, err := o.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if m.prepare != nil {
+			if err := m.prepare(o, ctx); err != nil {
+				return err
+			}
+		}
+
+		if err := o.applyMigration(ctx, m, m.up, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MigrateDown rolls back applied migrations newer than a given version
+func (o *OrderBook) MigrateDown(ctx context.Context, version uint32) error {
+	current, err := o.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= version || m.version > current {
+			continue
+		}
+
+		if err := o.applyMigration(ctx, m, m.down, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *OrderBook) applyMigration(ctx context.Context, m migration, statements []string, isApplied bool) error {
+	// Clickhouse DDL is not transactional, so the version is recorded only after all statements succeed
+	for _, statement := range statements {
+		if _, err := o.db.ExecContext(ctx, o.sql(statement)); err != nil {
+			return err
+		}
+	}
+
+	applied := uint8(0)
+	if isApplied {
+		applied = 1
+	}
+
+	return o.insertBlock(
+		ctx,
+		o.sql(`INSERT INTO {migrations} (version, name, isApplied, changedAt) VALUES (?, ?, ?, ?)`),
+		[][]interface{}{{m.version, m.name, applied, time.Now().UTC()}},
+	)
+}
@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"context"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		assert.Equal(t, uint32(i+1), m.version)
+		assert.NotEmpty(t, m.name)
+		assert.NotEmpty(t, m.up)
+		assert.NotEmpty(t, m.down)
+	}
+}
+
+func TestOrderBook_Migrate(t *testing.T) {
+	store, teardown := testDB(t)
+	defer teardown()
+	latest := migrations[len(migrations)-1].version
+
+	version, err := store.SchemaVersion(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, latest, version)
+
+	assert.Nil(t, store.MigrateDown(context.Background(), 0))
+	version, err = store.SchemaVersion(context.Background())
+	assert.Nil(t, err)
+	assert.Zero(t, version)
+
+	assert.Nil(t, store.Migrate(context.Background()))
+	version, err = store.SchemaVersion(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, latest, version)
+
+	// Applied migrations are skipped
+	assert.Nil(t, store.Migrate(context.Background()))
+}
+
+func TestOrderBook_MigrateLegacyOrders(t *testing.T) {
+	store, teardown := testDB(t)
+	defer teardown()
+
+	// Orders table as the order book created it before migrations
+	assert.Nil(t, store.MigrateDown(context.Background(), 0))
+	_, err := store.db.Exec(store.sql(`
+		CREATE TABLE IF NOT EXISTS {orders} (
+			id UUID,
+			tradeCode UUID,
+			validUntil Date,
+			price String,
+			quantity UInt32,
+			operation String,
+			counterParty String,
+			isEnabled UInt8,
+			type String
+		) engine=Memory
+	`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, store.Migrate(context.Background()))
+
+	order, err := models.NewGoodTillCancelledOrder(&models.OrderGeneralInfo{
+		TradeCode:    uuid.New(),
+		Price:        decimal.NewFromInt(10),
+		Quantity:     1,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, store.CreateOrder(context.Background(), order))
+
+	orderFromStore, err := store.OrderByID(context.Background(), order.ID)
+	assert.Nil(t, err)
+	if err == nil {
+		assert.Equal(t, order.Sequence, orderFromStore.Sequence)
+	}
+}
@@ -2,204 +2,874 @@ package clickhouse
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	_ "github.com/ClickHouse/clickhouse-go"
 	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
 	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/shopspring/decimal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Order columns without the storage version
+const orderColumns = `id, tradeCode, validUntil, price, quantity, operation, counterParty, isEnabled, createdAt,
+	sequence, originalQuantity, filledQuantity, averageFillPrice, status, type, stopPrice, displayQuantity, peakQuantity,
+	postOnly, isHidden, kind`
+
 type OrderBook struct {
-	db *sqlx.DB
+	// Last assigned arrival sequence, kept first for 64-bit atomic alignment
+	sequence uint64
+	db       *sqlx.DB
+	// Shared connection pools are left open on Close
+	ownsDB bool
+	// Serializes executions, since clickhouse has no row level locks
+	mu           sync.Mutex
+	config       Config
+	placeholders *strings.Replacer
+	// Prepared statements of queries by query text
+	stmtsMu sync.Mutex
+	stmts   map[string]*sqlx.Stmt
 }
 
-// Usually you want to get db connection from the function parameter,
-// but for our example we will establish it right here
-func New() (datastore.DataStore, error) {
-	db, err := sqlx.Open("clickhouse", "tcp://127.0.0.1:9000?compress=true&debug=true")
+// New establishes own connection to the server described by options,
+// it connects to a local server by default
+func New(options ...Option) (datastore.DataStore, error) {
+	config := newConfig(options)
+
+	dsn, err := config.dsn()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	db, err := sqlx.Open("clickhouse", dsn)
+	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-        CREATE TABLE IF NOT EXISTS orders (
-        	id UUID,
-        	tradeCode UUID,
-        	validUntil Date,
-        	price String,
-        	quantity UInt32,
-        	operation String,
-        	counterParty String,
-        	isEnabled UInt8,
-        	type String
-        ) engine=Memory
-    `)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+
+	orderBook, err := newOrderBook(db, config)
 	if err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
-	return &OrderBook{
-		db: db,
-	}, nil
+	orderBook.ownsDB = true
+	return orderBook, nil
+}
+
+// NewWithDB uses an existing connection pool, connection options are ignored
+func NewWithDB(db *sqlx.DB, options ...Option) (datastore.DataStore, error) {
+	return newOrderBook(db, newConfig(options))
+}
+
+func newOrderBook(db *sqlx.DB, config Config) (*OrderBook, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	orderBook := &OrderBook{
+		db:           db,
+		config:       config,
+		placeholders: config.placeholders(),
+		stmts:        make(map[string]*sqlx.Stmt),
+	}
+
+	if config.AutoMigrate {
+		if err := orderBook.Migrate(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	return orderBook, nil
+}
+
+// sql puts configured table names and price storage scale into the query
+func (o *OrderBook) sql(query string) string {
+	return o.placeholders.Replace(query)
 }
 
+// Close releases prepared statements and connection pool unless it was shared with the order book
 func (o *OrderBook) Close() error {
-	return o.db.Close()
+	err := o.closeStatements()
+	if !o.ownsDB {
+		return err
+	}
+
+	if dbErr := o.db.Close(); dbErr != nil {
+		return dbErr
+	}
+	return err
 }
 
 func (o *OrderBook) CreateOrder(ctx context.Context, order *models.Order) error {
-	if order == nil {
-		return datastore.ErrEmptyStruct
+	if err := o.checkOrder(order, true); err != nil {
+		return err
 	}
 
-	if order.ID == uuid.Nil {
-		return datastore.ErrZeroID
+	if err := o.checkInstrument(ctx, order); err != nil {
+		return err
+	}
+
+	// Executions must not interleave between the post-only check and the insert
+	if order.IsPostOnly() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+	}
+
+	if err := o.postOnly(ctx, order, nil); err != nil {
+		return err
+	}
+
+	o.arrive(order)
+	return o.insertOrders(ctx, []models.Order{*order})
+}
+
+// CreateOrders validates every order of the batch and writes the valid ones in a single block,
+// none of them is saved when the block is rejected
+func (o *OrderBook) CreateOrders(ctx context.Context, orders []*models.Order) ([]error, error) {
+	results := make([]error, len(orders))
+	accepted := make([]models.Order, 0, len(orders))
+	// Reference data is read once per instrument of the batch
+	instruments := make(map[uuid.UUID]*models.Instrument)
+
+	// Executions must not interleave between post-only checks and the insert
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, order := range orders {
+		if results[i] = o.checkOrder(order, true); results[i] != nil {
+			continue
+		}
+
+		instrument, ok := instruments[order.TradeCode]
+		if !ok {
+			var err error
+			if instrument, err = o.instrument(ctx, order.TradeCode); err != nil {
+				return nil, err
+			}
+			instruments[order.TradeCode] = instrument
+		}
+
+		if results[i] = datastore.ValidateOrderForInstrument(instrument, order); results[i] != nil {
+			continue
+		}
+
+		// Orders accepted earlier in the batch are not stored yet, but are part of the book as well
+		if results[i] = o.postOnly(ctx, order, accepted); results[i] != nil {
+			continue
+		}
+
+		o.arrive(order)
+		accepted = append(accepted, *order)
+	}
+
+	if err := o.insertOrders(ctx, accepted); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// checkOrder validates the order and its price against the instrument storage,
+// orders to be rested as is are validated for that as well
+func (o *OrderBook) checkOrder(order *models.Order, isResting bool) error {
+	validate := datastore.ValidateOrder
+	if isResting {
+		validate = datastore.ValidateRestingOrder
+	}
+
+	if err := validate(order); err != nil {
+		return err
+	}
+
+	if err := checkPrice(order.Price); err != nil {
+		return err
+	}
+
+	return checkPrice(order.StopPrice)
+}
+
+// postOnly rejects or reprices post-only order which would take liquidity of the stored book
+// or of pending orders about to be stored with it, caller must hold the lock
+func (o *OrderBook) postOnly(ctx context.Context, order *models.Order, pending []models.Order) error {
+	if !order.IsPostOnly() {
+		return nil
 	}
 
-	tx, err := o.db.BeginTx(ctx, nil)
-	stmt, err := tx.Prepare(
-		`INSERT INTO orders 
-					(id, tradeCode, validUntil, price, quantity, operation, counterParty, isEnabled, type)
-					VALUES
-					(?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`)
+	crossed, err := o.matchOrderByOperation(ctx, order.TradeCode, order.Operation, matchingLimit(order))
 	if err != nil {
 		return err
 	}
 
-	isEnabled := uint8(0)
-	if order.IsEnabled {
-		isEnabled = uint8(1)
+	var best *models.Order
+	if len(crossed) > 0 {
+		best = &crossed[0]
+	}
+
+	for i, resting := range pending {
+		if resting.TradeCode != order.TradeCode || resting.Operation == order.Operation {
+			continue
+		}
+
+		if !resting.IsProcessable() || resting.IsStop() || resting.Quantity == 0 || !order.Crosses(resting) {
+			continue
+		}
+
+		if best == nil || resting.HasPriorityOver(*best) {
+			best = &pending[i]
+		}
+	}
+
+	if best == nil {
+		return nil
 	}
 
-	if _, err := stmt.ExecContext(
-		ctx,
-		order.ID,
-		order.TradeCode,
-		order.ValidUntil,
-		order.Price.String(),
-		uint32(order.Quantity),
-		order.Operation,
-		order.CounterParty,
-		isEnabled,
-		order.Type,
-	); err != nil {
+	instrument, err := o.instrument(ctx, order.TradeCode)
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.ApplyPostOnly(order, best, instrument); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	return checkPrice(order.Price)
+}
+
+// insertOrders writes a new version of every order in a single block,
+// reads with FINAL see only the latest version right after the insert
+func (o *OrderBook) insertOrders(ctx context.Context, orders []models.Order) error {
+	rows := make([][]interface{}, 0, len(orders))
+	for _, order := range orders {
+		isEnabled := uint8(0)
+		if order.IsEnabled {
+			isEnabled = uint8(1)
+		}
+
+		isHidden := uint8(0)
+		if order.IsHidden {
+			isHidden = uint8(1)
+		}
+
+		rows = append(rows, []interface{}{
+			order.ID,
+			order.TradeCode,
+			order.ValidUntil,
+			priceToStorage(order.Price),
+			uint32(order.Quantity),
+			order.Operation,
+			order.CounterParty,
+			isEnabled,
+			order.CreatedAt,
+			order.Sequence,
+			uint32(order.OriginalQuantity),
+			uint32(order.FilledQuantity),
+			order.AverageFillPrice.String(),
+			order.Status,
+			order.Type,
+			priceToStorage(order.StopPrice),
+			uint32(order.DisplayQuantity),
+			uint32(order.PeakQuantity),
+			order.PostOnly,
+			isHidden,
+			order.Kind,
+			o.nextSequence(time.Now().UTC()),
+		})
+	}
+
+	return o.insertBlock(ctx, o.sql(`
+		INSERT INTO {orders}
+			(`+orderColumns+`, version)
+			VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), rows)
 }
 
+// arrive stamps order arrival and displays the first peak of iceberg order
+func (o *OrderBook) arrive(order *models.Order) {
+	order.CreatedAt = time.Now().UTC()
+	order.Sequence = o.nextSequence(order.CreatedAt)
+	order.Open()
+	order.Replenish()
+}
+
+// nextSequence derives arrival sequence from the arrival time,
+// keeping it strictly increasing for orders created by this process
+func (o *OrderBook) nextSequence(arrival time.Time) uint64 {
+	for {
+		last := atomic.LoadUint64(&o.sequence)
+		next := uint64(arrival.UnixNano())
+		if next <= last {
+			next = last + 1
+		}
+
+		if atomic.CompareAndSwapUint64(&o.sequence, last, next) {
+			return next
+		}
+	}
+}
+
+// DisableOrder to remove it from market snapshot and other reads,
+// cancellation is visible to the next read since it is a new version of the order
 func (o *OrderBook) DisableOrder(ctx context.Context, id uuid.UUID) error {
 	if id == uuid.Nil {
 		return datastore.ErrZeroID
 	}
 
-	stmt, err := o.db.Prepare(`ALTER TABLE orders UPDATE isEnabled = 0 WHERE id = ?`)
+	// Executions must not interleave between the read and the new version
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	order, err := o.latestOrder(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Filled orders stay filled
+	if !order.IsProcessable() || order.Status == models.Filled {
+		return nil
+	}
+
+	order.Cancel()
+	return o.insertOrders(ctx, []models.Order{*order})
+}
+
+// AmendOrder changes price and quantity left to fill of the order keeping its ID,
+// time priority is kept on quantity reduction and lost on any other change.
+// Price crossing the book is rejected, post-only order follows its post-only mode
+func (o *OrderBook) AmendOrder(ctx context.Context, id uuid.UUID, price decimal.Decimal, quantity uint) error {
+	if id == uuid.Nil {
+		return datastore.ErrZeroID
+	}
+
+	if !price.IsPositive() {
+		return datastore.ErrNonPositivePrice
+	}
+
+	if quantity == 0 {
+		return datastore.ErrZeroQuantity
+	}
+
+	// Executions must not interleave between the read and the update
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	order, err := o.OrderByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if _, err := stmt.ExecContext(ctx, id); err != nil {
+	if err := checkPrice(price); err != nil {
+		return err
+	}
+
+	info := *order.OrderGeneralInfo
+	amended := *order
+	amended.OrderGeneralInfo = &info
+	amended.Price = price
+	amended.Quantity = quantity
+	if err := o.checkInstrument(ctx, &amended); err != nil {
 		return err
 	}
 
-	return nil
+	// Filled orders can't be revived by amendment
+	if order.Quantity == 0 {
+		return datastore.ErrOrderDoesNotExist
+	}
+
+	// Amendment never executes, so the order can't be moved to a price it would cross at,
+	// post-only order is rejected or repriced by its mode instead
+	if !price.Equal(order.Price) {
+		if err := o.postOnly(ctx, &amended, nil); err != nil {
+			return err
+		}
+
+		crossed, err := o.matchOrderByOperation(ctx, order.TradeCode, order.Operation, &amended.Price)
+		if err != nil {
+			return err
+		}
+
+		if len(crossed) > 0 {
+			return datastore.ErrCrossingAmend
+		}
+		price = amended.Price
+	}
+
+	keepsPriority := order.KeepsPriorityOn(price, quantity)
+	if !keepsPriority {
+		order.Sequence = o.nextSequence(time.Now().UTC())
+	}
+
+	order.Price = price
+	order.Quantity = quantity
+	order.OriginalQuantity = order.FilledQuantity + quantity
+	if !keepsPriority || order.PeakQuantity > quantity {
+		order.Replenish()
+	}
+	return o.insertOrders(ctx, []models.Order{*order})
 }
 
+// OrderByID returns only enabled and not expired order
 func (o *OrderBook) OrderByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
 	if id == uuid.Nil {
 		return nil, datastore.ErrZeroID
 	}
 
-	stmt, err := o.db.Preparex(`SELECT * FROM orders WHERE id = ?`)
+	order, err := o.latestOrder(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	order := new(models.Order)
-	if err := stmt.Get(order, id); err != nil {
+	if !order.IsProcessable() {
+		return nil, datastore.ErrOrderDoesNotExist
+	}
+
+	return order, nil
+}
+
+// FindOrder returns the order whatever its status is, with expired status for open order past its validity
+func (o *OrderBook) FindOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	if id == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	order, err := o.latestOrder(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 
-	if !order.IsProcessable() {
-		return nil, datastore.ErrOrderDoesNotExist
+	order.Status = order.ActualStatus()
+	return order, nil
+}
+
+// latestOrder returns the latest version of the order whatever its status is
+func (o *OrderBook) latestOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	stmt, err := o.stmt(ctx, o.sql(`SELECT `+orderColumns+` FROM {orders} FINAL WHERE id = ?`))
+	if err != nil {
+		return nil, err
 	}
 
+	order := new(models.Order)
+	if err := stmt.GetContext(ctx, order, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, datastore.ErrOrderDoesNotExist
+		}
+		return nil, err
+	}
+
+	orderFromStorage(order)
 	return order, nil
 }
 
 func (o *OrderBook) MatchOrder(ctx context.Context, order *models.Order) ([]models.Order, error) {
-	if order == nil {
-		return nil, datastore.ErrEmptyStruct
+	if err := datastore.ValidateMatchingOrder(order); err != nil {
+		return nil, err
 	}
 
-	return o.matchOrderByOperation(order.Operation, order.Price)
+	return o.matchOrderByOperation(ctx, order.TradeCode, order.Operation, matchingLimit(order))
 }
 
-func (o *OrderBook) matchOrderByOperation(operation models.MarketOperation, price decimal.Decimal) ([]models.Order, error) {
+func (o *OrderBook) matchOrderByOperation(
+	ctx context.Context,
+	tradeCode uuid.UUID,
+	operation models.MarketOperation,
+	limit *decimal.Decimal,
+) ([]models.Order, error) {
 	query := `
-		SELECT *
-		FROM orders
+		SELECT ` + orderColumns + `
+		FROM {orders} FINAL
 		WHERE 
 			operation = 'ask'
+				AND tradeCode = ?
 				AND isEnabled = 1
-				AND now('Europe/London') <= validUntil
-				AND toFloat64(price) <= toFloat64(?)
+				AND validUntil > fromUnixTimestamp64Nano(toInt64(?))
+				%s
 				AND quantity > 0
+				AND kind NOT IN ('stop-market', 'stop-limit')
+		ORDER BY price, sequence
 	`
+	pricePredicate := `AND price <= toDecimal64(?, {priceScale})`
 
 	if operation == models.Ask {
 		query = `
-			SELECT *
-			FROM orders
+			SELECT ` + orderColumns + `
+			FROM {orders} FINAL
 			WHERE operation = 'bid'
+				AND tradeCode = ?
 				AND isEnabled = 1
-				AND now('Europe/London') <= validUntil
-				AND toFloat64(price) >= toFloat64(?)
+				AND validUntil > fromUnixTimestamp64Nano(toInt64(?))
+				%s
 				AND quantity > 0
+				AND kind NOT IN ('stop-market', 'stop-limit')
+			ORDER BY price DESC, sequence
 		`
+		pricePredicate = `AND price >= toDecimal64(?, {priceScale})`
+	}
+
+	// Expiry is checked against the client clock in nanoseconds, the same way as models.Order.IsProcessable does
+	args := []interface{}{tradeCode, time.Now().UTC().UnixNano()}
+
+	// Price is bound as a string to be parsed by the server exactly,
+	// order without a limit takes the whole side
+	if limit != nil {
+		args = append(args, limit.String())
+	} else {
+		pricePredicate = ""
 	}
 
 	matchingOrders := make([]models.Order, 0)
 
-	stmt, err := o.db.Preparex(query)
+	stmt, err := o.stmt(ctx, o.sql(fmt.Sprintf(query, pricePredicate)))
 	if err != nil {
 		return nil, err
 	}
 
-	err = stmt.Select(&matchingOrders, price.String())
+	err = stmt.SelectContext(ctx, &matchingOrders, args...)
 	if err != nil {
 		return nil, err
 	}
 
+	// General info is shared by pointer, so ranging over values is enough
+	for _, order := range matchingOrders {
+		orderFromStorage(&order)
+	}
+
 	return matchingOrders, nil
 }
 
-func (o *OrderBook) MarketDataSnapshot(ctx context.Context) (*models.MarketDataSnapshot, error) {
-	stmt, err := o.db.Preparex(`
-		SELECT price, quantity
-		FROM orders
-		WHERE operation = ? AND isEnabled = 1 AND now('Europe/London') <= validUntil
-		ORDER BY toFloat64(price)
-	`)
+// ExecuteOrder fills order against the opposite side of its instrument in price-time priority,
+// decrements quantities of both sides and rests the remainder in the book.
+// Remainder of immediate-or-cancel order is cancelled, fill-or-kill order
+// which can't be filled completely leaves the book untouched.
+// Stop order waits in the trigger book until the last trade price reaches it
+func (o *OrderBook) ExecuteOrder(ctx context.Context, order *models.Order) ([]models.Trade, error) {
+	// Checked upfront, since the remainder can't be rested after the fills otherwise
+	if err := o.checkOrder(order, false); err != nil {
+		return nil, err
+	}
+
+	if err := o.checkInstrument(ctx, order); err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.postOnly(ctx, order, nil); err != nil {
+		return nil, err
+	}
+
+	if order.IsStop() {
+		lastPrice, err := o.lastTradePrice(ctx, order.TradeCode)
+		if err != nil && err != datastore.ErrNoLastTradePrice {
+			return nil, err
+		}
+
+		if err != nil || !order.IsTriggeredBy(lastPrice) {
+			o.arrive(order)
+			return make([]models.Trade, 0), o.insertOrders(ctx, []models.Order{*order})
+		}
+
+		order.Trigger()
+	}
+
+	trades, err := o.execute(ctx, order, false)
+	if err != nil || len(trades) == 0 {
+		return trades, err
+	}
+
+	stopTrades, err := o.triggerStops(ctx, order.TradeCode, trades[len(trades)-1].Price)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(trades, stopTrades...), nil
+}
+
+// execute matches the order and rests or cancels its remainder writing all the changes in a block per table,
+// orders stored before the execution get their final version written too, caller must hold the lock
+func (o *OrderBook) execute(ctx context.Context, order *models.Order, isStored bool) ([]models.Trade, error) {
+	candidates, err := o.matchOrderByOperation(ctx, order.TradeCode, order.Operation, matchingLimit(order))
 	if err != nil {
 		return nil, err
 	}
 
-	asks := make([]models.OrderSnapshot, 0)
-	err = stmt.Select(&asks, "ask")
+	// Fill-or-kill order which can't be filled completely leaves the book untouched
+	if order.Type == models.FillOrKill && models.TotalQuantity(candidates) < order.Quantity {
+		candidates = nil
+	}
+
+	trades := make([]models.Trade, 0)
+	filled := make([]models.Order, 0)
+	isFilled := make(map[uuid.UUID]bool)
+	for i := 0; i < len(candidates) && order.Quantity > 0; i++ {
+		resting := candidates[i]
+		quantity := resting.VisibleQuantity()
+		if order.Quantity < quantity {
+			quantity = order.Quantity
+		}
+
+		// Requeued iceberg order shares general info with its previous position, so it is written once
+		resting.Fill(quantity, resting.Price)
+		order.Fill(quantity, resting.Price)
+
+		if !isFilled[resting.ID] {
+			isFilled[resting.ID] = true
+			filled = append(filled, resting)
+		}
+		trades = append(trades, *models.NewTrade(*order, resting, quantity))
+
+		// Iceberg order with a filled peak goes behind its price level with a new one
+		if resting.NeedsReplenishment() {
+			resting.Replenish()
+			resting.Sequence = o.nextSequence(time.Now().UTC())
+			candidates = models.Requeue(candidates, i, resting)
+		}
+	}
+
+	rests := order.Quantity > 0 && order.RestsInBook()
+	if rests {
+		o.arrive(order)
+	} else if order.Quantity > 0 {
+		order.Cancel()
+	}
+
+	// Remainder rests in the same block as the new versions of the filled orders
+	if rests || isStored {
+		filled = append(filled, *order)
+	}
+
+	if err := o.insertOrders(ctx, filled); err != nil {
+		return nil, err
+	}
+
+	if err := o.insertTrades(ctx, trades); err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// triggerStops executes stop orders of the instrument reached by the last trade price one by one in arrival order,
+// since every execution may move the price further, caller must hold the lock
+func (o *OrderBook) triggerStops(ctx context.Context, tradeCode uuid.UUID, lastPrice decimal.Decimal) ([]models.Trade, error) {
+	trades := make([]models.Trade, 0)
+	for {
+		stop, err := o.nextTriggered(ctx, tradeCode, lastPrice)
+		if err != nil {
+			return nil, err
+		}
+
+		if stop == nil {
+			return trades, nil
+		}
+
+		// Triggered order is written with its new type, so it is never triggered again
+		stop.Trigger()
+		stopTrades, err := o.execute(ctx, stop, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(stopTrades) > 0 {
+			lastPrice = stopTrades[len(stopTrades)-1].Price
+		}
+		trades = append(trades, stopTrades...)
+	}
+}
+
+// nextTriggered returns the earliest stop order reached by the last trade price or nil when there is none
+func (o *OrderBook) nextTriggered(ctx context.Context, tradeCode uuid.UUID, lastPrice decimal.Decimal) (*models.Order, error) {
+	stmt, err := o.stmt(ctx, o.sql(`
+		SELECT `+orderColumns+`
+		FROM {orders} FINAL
+		WHERE tradeCode = ?
+			AND kind IN ('stop-market', 'stop-limit')
+			AND isEnabled = 1
+			AND validUntil > fromUnixTimestamp64Nano(toInt64(?))
+			AND quantity > 0
+			AND (
+				(operation = 'bid' AND stopPrice <= toDecimal64(?, {priceScale}))
+				OR (operation = 'ask' AND stopPrice >= toDecimal64(?, {priceScale}))
+			)
+		ORDER BY sequence
+		LIMIT 1
+	`))
 	if err != nil {
 		return nil, err
 	}
 
-	bids := make([]models.OrderSnapshot, 0)
-	err = stmt.Select(&bids, "bid")
+	stop := new(models.Order)
+	err = stmt.GetContext(ctx, stop, tradeCode, time.Now().UTC().UnixNano(), lastPrice.String(), lastPrice.String())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	orderFromStorage(stop)
+	return stop, nil
+}
+
+// RecordTrade appends trade to the trade tape and triggers stop orders reached by the last trade price
+func (o *OrderBook) RecordTrade(ctx context.Context, trade *models.Trade) error {
+	if trade == nil {
+		return datastore.ErrEmptyStruct
+	}
+
+	if trade.ID == uuid.Nil {
+		return datastore.ErrZeroID
+	}
+
+	// Stop orders must not interleave with executions
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.insertTrades(ctx, []models.Trade{*trade}); err != nil {
+		return err
+	}
+
+	// Recorded trade may be older than the latest one
+	lastPrice, err := o.lastTradePrice(ctx, trade.TradeCode)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.triggerStops(ctx, trade.TradeCode, lastPrice)
+	return err
+}
+
+// LastTradePrice is the price of the latest execution of the instrument
+func (o *OrderBook) LastTradePrice(ctx context.Context, tradeCode uuid.UUID) (decimal.Decimal, error) {
+	if tradeCode == uuid.Nil {
+		return decimal.Zero, datastore.ErrZeroID
+	}
+
+	return o.lastTradePrice(ctx, tradeCode)
+}
+
+func (o *OrderBook) lastTradePrice(ctx context.Context, tradeCode uuid.UUID) (decimal.Decimal, error) {
+	stmt, err := o.stmt(ctx, o.sql(`
+		SELECT count() AS trades, argMax(price, executedAt) AS price
+		FROM {trades}
+		WHERE tradeCode = ?
+	`))
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var last struct {
+		Trades uint64          `db:"trades"`
+		Price  decimal.Decimal `db:"price"`
+	}
+	if err := stmt.GetContext(ctx, &last, tradeCode); err != nil {
+		return decimal.Zero, err
+	}
+
+	if last.Trades == 0 {
+		return decimal.Zero, datastore.ErrNoLastTradePrice
+	}
+
+	return priceFromStorage(last.Price), nil
+}
+
+// insertTrades writes trades in a single block
+func (o *OrderBook) insertTrades(ctx context.Context, trades []models.Trade) error {
+	rows := make([][]interface{}, 0, len(trades))
+	for _, trade := range trades {
+		rows = append(rows, []interface{}{
+			trade.ID,
+			trade.BuyOrderID,
+			trade.SellOrderID,
+			trade.BuyCounterParty,
+			trade.SellCounterParty,
+			trade.TradeCode,
+			priceToStorage(trade.Price),
+			uint32(trade.Quantity),
+			trade.ExecutedAt,
+			trade.Aggressor,
+		})
+	}
+
+	return o.insertBlock(ctx, o.sql(`
+		INSERT INTO {trades}
+			(id, buyOrderID, sellOrderID, buyCounterParty, sellCounterParty, tradeCode, price, quantity, executedAt, aggressor)
+			VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), rows)
+}
+
+// Trades returns trade history passing the filter ordered by execution time
+func (o *OrderBook) Trades(ctx context.Context, filter models.TradeFilter) ([]models.Trade, error) {
+	query := o.sql(`SELECT * FROM {trades} WHERE 1 = 1`)
+	args := make([]interface{}, 0)
+
+	if filter.TradeCode != uuid.Nil {
+		query += ` AND tradeCode = ?`
+		args = append(args, filter.TradeCode)
+	}
+
+	if filter.CounterParty != "" {
+		query += ` AND (buyCounterParty = ? OR sellCounterParty = ?)`
+		args = append(args, filter.CounterParty, filter.CounterParty)
+	}
+
+	// Bound as nanoseconds, since the driver truncates time parameters to seconds
+	if filter.From != nil {
+		query += ` AND executedAt >= fromUnixTimestamp64Nano(toInt64(?))`
+		args = append(args, filter.From.UnixNano())
+	}
+
+	if filter.To != nil {
+		query += ` AND executedAt < fromUnixTimestamp64Nano(toInt64(?))`
+		args = append(args, filter.To.UnixNano())
+	}
+
+	query += ` ORDER BY executedAt`
+
+	stmt, err := o.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]models.Trade, 0)
+	if err := stmt.SelectContext(ctx, &trades, args...); err != nil {
+		return nil, err
+	}
+
+	for i := range trades {
+		trades[i].Price = priceFromStorage(trades[i].Price)
+	}
+
+	return trades, nil
+}
+
+// MarketDataSnapshot to get actual price levels of the instrument ordered best price first,
+// depth limits number of the best levels of each side, zero depth means no limit.
+// Hidden orders are left out
+func (o *OrderBook) MarketDataSnapshot(
+	ctx context.Context,
+	tradeCode uuid.UUID,
+	depth uint,
+) (*models.MarketDataSnapshot, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	asks, err := o.priceLevels(ctx, tradeCode, models.Ask, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	bids, err := o.priceLevels(ctx, tradeCode, models.Bid, depth)
 	if err != nil {
 		return nil, err
 	}
@@ -209,3 +879,79 @@ func (o *OrderBook) MarketDataSnapshot(ctx context.Context) (*models.MarketDataS
 		Bids: bids,
 	}, nil
 }
+
+// priceLevels aggregates the best displayed price levels of the side ordered best first
+func (o *OrderBook) priceLevels(
+	ctx context.Context,
+	tradeCode uuid.UUID,
+	operation models.MarketOperation,
+	depth uint,
+) ([]models.PriceLevel, error) {
+	direction := "ASC"
+	if operation == models.Bid {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(o.sql(`
+		SELECT price, sum(if(displayQuantity > 0, peakQuantity, quantity)) AS quantity, count() AS orders
+		FROM {orders} FINAL
+		WHERE tradeCode = ?
+			AND operation = ?
+			AND isEnabled = 1
+			AND validUntil > fromUnixTimestamp64Nano(toInt64(?))
+			AND quantity > 0
+			AND kind NOT IN ('stop-market', 'stop-limit')
+			AND isHidden = 0
+		GROUP BY price
+		ORDER BY price %s
+	`), direction)
+	args := []interface{}{tradeCode, operation, time.Now().UTC().UnixNano()}
+
+	if depth > 0 {
+		query += ` LIMIT ?`
+		args = append(args, depth)
+	}
+
+	stmt, err := o.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := make([]models.PriceLevel, 0)
+	if err := stmt.SelectContext(ctx, &levels, args...); err != nil {
+		return nil, err
+	}
+
+	for i := range levels {
+		levels[i].Price = priceFromStorage(levels[i].Price)
+	}
+
+	return levels, nil
+}
+
+// BestBidOffer to get top of the instrument book without building a full snapshot
+func (o *OrderBook) BestBidOffer(ctx context.Context, tradeCode uuid.UUID) (*models.BestBidOffer, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	asks, err := o.priceLevels(ctx, tradeCode, models.Ask, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	bids, err := o.priceLevels(ctx, tradeCode, models.Bid, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var bestAsk, bestBid *models.PriceLevel
+	if len(asks) > 0 {
+		bestAsk = &asks[0]
+	}
+	if len(bids) > 0 {
+		bestBid = &bids[0]
+	}
+
+	return models.NewBestBidOffer(tradeCode, bestBid, bestAsk), nil
+}
@@ -0,0 +1,60 @@
+package clickhouse
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/shopspring/decimal"
+)
+
+// Prices are stored as Decimal64 with the fixed storage scale,
+// instruments may use any scale up to it, which is checked against their reference data
+const priceScale int32 = 8
+
+// Decimal64 keeps 18 significant digits, so the storage scale leaves 10 for the integer part
+var maxPrice = decimal.New(1, 18-priceScale)
+
+// priceToStorage gives raw Decimal64 value, the driver writes decimals as unscaled integers
+func priceToStorage(price decimal.Decimal) int64 {
+	return price.Shift(priceScale).IntPart()
+}
+
+// priceFromStorage rescales raw Decimal64 value, the driver reads decimals as unscaled integers.
+// Trailing zeros are dropped, so the price is the same as parsed from its shortest form
+func priceFromStorage(price decimal.Decimal) decimal.Decimal {
+	value, exp := price.IntPart(), -priceScale
+	for exp < 0 && value%10 == 0 {
+		value /= 10
+		exp++
+	}
+
+	return decimal.New(value, exp)
+}
+
+// checkPrice rejects prices which can't be stored exactly
+func checkPrice(price decimal.Decimal) error {
+	if !price.Equal(price.Truncate(priceScale)) {
+		return datastore.ErrPriceScale
+	}
+
+	if price.Abs().GreaterThanOrEqual(maxPrice) {
+		return datastore.ErrPriceOutOfRange
+	}
+
+	return nil
+}
+
+// orderFromStorage rescales prices of the order read from the storage
+func orderFromStorage(order *models.Order) {
+	order.Price = priceFromStorage(order.Price)
+	order.StopPrice = priceFromStorage(order.StopPrice)
+}
+
+// matchingLimit is the worst price the order accepts,
+// nil for market orders without protection which accept any price
+func matchingLimit(order *models.Order) *decimal.Decimal {
+	if !order.HasPriceLimit() {
+		return nil
+	}
+
+	return &order.Price
+}
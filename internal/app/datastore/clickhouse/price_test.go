@@ -0,0 +1,38 @@
+package clickhouse
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckPrice(t *testing.T) {
+	assert.Nil(t, checkPrice(decimal.RequireFromString("10.12345678")))
+	assert.Nil(t, checkPrice(decimal.RequireFromString("10.123456780")))
+	assert.Equal(t, datastore.ErrPriceScale, checkPrice(decimal.RequireFromString("10.123456789")))
+	assert.Equal(t, datastore.ErrPriceOutOfRange, checkPrice(decimal.RequireFromString("10000000000")))
+}
+
+func TestPriceStorage(t *testing.T) {
+	price := decimal.RequireFromString("0.10000001")
+	assert.Equal(t, int64(10000001), priceToStorage(price))
+	assert.Equal(t, price, priceFromStorage(decimal.NewFromInt(priceToStorage(price))))
+	assert.Equal(t, decimal.NewFromInt(32), priceFromStorage(decimal.NewFromInt(3200000000)))
+	assert.Equal(t, decimal.RequireFromString("10.5"), priceFromStorage(decimal.NewFromInt(1050000000)))
+}
+
+func TestMatchingLimit(t *testing.T) {
+	newOrder := func(kind models.OrderKind, operation models.MarketOperation, price int64) *models.Order {
+		return &models.Order{
+			OrderGeneralInfo: &models.OrderGeneralInfo{Price: decimal.NewFromInt(price), Operation: operation},
+			Kind:             kind,
+		}
+	}
+
+	assert.True(t, decimal.NewFromInt(10).Equal(*matchingLimit(newOrder(models.Limit, models.Bid, 10))))
+	assert.True(t, decimal.NewFromInt(10).Equal(*matchingLimit(newOrder(models.Market, models.Ask, 10))))
+	assert.Nil(t, matchingLimit(newOrder(models.Market, models.Bid, 0)))
+	assert.Nil(t, matchingLimit(newOrder(models.Market, models.Ask, 0)))
+}
@@ -0,0 +1,17 @@
+//go:build integration
+// +build integration
+
+package clickhouse
+
+import (
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	store, err := New()
+	if err != nil {
+		t.Fatal("no db connection: ", err)
+	}
+	orderBook := store.(*OrderBook)
+	defer orderBook.Close()
+}
@@ -0,0 +1,74 @@
+package clickhouse
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// stmt returns prepared statement of the query,
+// statements are prepared once and closed along with the order book
+func (o *OrderBook) stmt(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	o.stmtsMu.Lock()
+	defer o.stmtsMu.Unlock()
+
+	if stmt, ok := o.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := o.db.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	o.stmts[query] = stmt
+	return stmt, nil
+}
+
+// closeStatements releases all the cached statements
+func (o *OrderBook) closeStatements() error {
+	o.stmtsMu.Lock()
+	defer o.stmtsMu.Unlock()
+
+	var firstErr error
+	for query, stmt := range o.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(o.stmts, query)
+	}
+
+	return firstErr
+}
+
+// insertBlock writes rows with a single native block insert,
+// the driver sends the block on commit, so nothing is written unless all the rows are accepted
+func (o *OrderBook) insertBlock(ctx context.Context, query string, rows [][]interface{}) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,69 @@
+package clickhouse
+
+import (
+	"context"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOrderBook_insertBlock(t *testing.T) {
+	db, teardown := testDB(t)
+	defer teardown()
+
+	tradeCode := uuid.New()
+	query := db.sql(`
+		INSERT INTO {trades}
+			(id, tradeCode, price, quantity, executedAt)
+			VALUES
+			(?, ?, ?, ?, ?)
+	`)
+
+	// The whole block is discarded when any row is rejected
+	err := db.insertBlock(context.Background(), query, [][]interface{}{
+		{uuid.New(), tradeCode, int64(3200000000), uint32(1), time.Now().UTC()},
+		{uuid.New(), tradeCode, "32", uint32(1), time.Now().UTC()},
+	})
+	assert.NotNil(t, err)
+
+	trades, err := db.Trades(context.Background(), models.TradeFilter{TradeCode: tradeCode})
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+	assert.Zero(t, db.db.Stats().InUse)
+
+	err = db.insertBlock(context.Background(), query, [][]interface{}{
+		{uuid.New(), tradeCode, int64(3200000000), uint32(1), time.Now().UTC()},
+		{uuid.New(), tradeCode, int64(3100000000), uint32(2), time.Now().UTC()},
+	})
+	assert.Nil(t, err)
+
+	trades, err = db.Trades(context.Background(), models.TradeFilter{TradeCode: tradeCode})
+	assert.Nil(t, err)
+	assert.Len(t, trades, 2)
+	assert.Zero(t, db.db.Stats().InUse)
+}
+
+func TestOrderBook_stmt(t *testing.T) {
+	db, teardown := testDB(t)
+	defer teardown()
+
+	query := db.sql(`SELECT ` + orderColumns + ` FROM {orders} FINAL WHERE id = ?`)
+
+	stmt, err := db.stmt(context.Background(), query)
+	assert.Nil(t, err)
+
+	// Statements are prepared once per query
+	cached, err := db.stmt(context.Background(), query)
+	assert.Nil(t, err)
+	assert.Same(t, stmt, cached)
+
+	assert.Nil(t, db.closeStatements())
+	assert.Empty(t, db.stmts)
+
+	// Closed statements are prepared again on demand
+	_, err = db.OrderByID(context.Background(), uuid.New())
+	assert.NotNil(t, err)
+	assert.Len(t, db.stmts, 1)
+}
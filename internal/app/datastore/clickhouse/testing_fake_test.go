@@ -0,0 +1,27 @@
+//go:build !integration
+// +build !integration
+
+package clickhouse
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore/clickhouse/fake"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func newTestOrderBook() (*OrderBook, error) {
+	// Every order book gets a database of its own
+	db, err := sqlx.Open(fake.DriverName, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewWithDB(db)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBook := store.(*OrderBook)
+	orderBook.ownsDB = true
+	return orderBook, nil
+}
@@ -0,0 +1,13 @@
+//go:build integration
+// +build integration
+
+package clickhouse
+
+func newTestOrderBook() (*OrderBook, error) {
+	store, err := New()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.(*OrderBook), nil
+}
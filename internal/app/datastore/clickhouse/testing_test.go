@@ -0,0 +1,34 @@
+package clickhouse
+
+import (
+	"testing"
+)
+
+// testDB gives an order book backed by the fake server,
+// or by a local clickhouse server when built with the integration tag
+func testDB(t *testing.T) (*OrderBook, func()) {
+	t.Helper()
+
+	orderBook, err := newTestOrderBook()
+	if err != nil {
+		t.Fatal("no db connection: ", err)
+	}
+
+	return orderBook, func() {
+		if _, err := orderBook.db.Exec(orderBook.sql("TRUNCATE TABLE IF EXISTS {orders}")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := orderBook.db.Exec(orderBook.sql("TRUNCATE TABLE IF EXISTS {trades}")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := orderBook.db.Exec(orderBook.sql("TRUNCATE TABLE IF EXISTS {instruments}")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := orderBook.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -4,13 +4,35 @@ import (
 	"context"
 	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 // DataStore interface to make sure we switch between databases easily
 type DataStore interface {
+	// CreateOrder saves the order, post-only order which would cross the book is rejected or repriced
 	CreateOrder(ctx context.Context, order *models.Order) error
+	// CreateOrders saves valid orders of the batch at once,
+	// rejection reason of every order is at its index and is nil for the saved ones
+	CreateOrders(ctx context.Context, orders []*models.Order) ([]error, error)
 	DisableOrder(ctx context.Context, id uuid.UUID) error
+	AmendOrder(ctx context.Context, id uuid.UUID, price decimal.Decimal, quantity uint) error
 	OrderByID(ctx context.Context, id uuid.UUID) (*models.Order, error)
+	// FindOrder returns the order whatever its status is, unlike OrderByID which returns processable orders only.
+	// Open order past its validity is reported expired
+	FindOrder(ctx context.Context, id uuid.UUID) (*models.Order, error)
 	MatchOrder(ctx context.Context, order *models.Order) ([]models.Order, error)
-	MarketDataSnapshot(ctx context.Context) (*models.MarketDataSnapshot, error)
+	// ExecuteOrder gives trades of the order followed by trades of stop orders it triggered,
+	// stop orders are put to the trigger book unless the last trade price has already reached them
+	ExecuteOrder(ctx context.Context, order *models.Order) ([]models.Trade, error)
+	// MarketDataSnapshot and BestBidOffer show displayed quantities only, hidden orders are left out
+	MarketDataSnapshot(ctx context.Context, tradeCode uuid.UUID, depth uint) (*models.MarketDataSnapshot, error)
+	BestBidOffer(ctx context.Context, tradeCode uuid.UUID) (*models.BestBidOffer, error)
+	RecordTrade(ctx context.Context, trade *models.Trade) error
+	Trades(ctx context.Context, filter models.TradeFilter) ([]models.Trade, error)
+	// LastTradePrice is the price of the latest execution of the instrument, it triggers stop orders
+	LastTradePrice(ctx context.Context, tradeCode uuid.UUID) (decimal.Decimal, error)
+	// SaveInstrument creates or replaces reference data of the instrument,
+	// orders of instruments without reference data are not checked against it
+	SaveInstrument(ctx context.Context, instrument *models.Instrument) error
+	InstrumentByTradeCode(ctx context.Context, tradeCode uuid.UUID) (*models.Instrument, error)
 }
@@ -0,0 +1,1335 @@
+// Package datastoretest provides the conformance suite every DataStore implementation is tested with
+package datastoretest
+
+import (
+	"context"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// StoreFactory gives a store to run a conformance case against and its teardown.
+// Stores may be shared between cases, every case works with its own instruments
+type StoreFactory func(t *testing.T) (datastore.DataStore, func())
+
+// RunConformanceTests checks that the store behaves the way every DataStore implementation must
+func RunConformanceTests(t *testing.T, newStore StoreFactory) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, store datastore.DataStore)
+	}{
+		{"CreateOrder", testCreateOrder},
+		{"CreateOrders", testCreateOrders},
+		{"DisableOrder", testDisableOrder},
+		{"AmendOrder", testAmendOrder},
+		{"OrderByID", testOrderByID},
+		{"MatchOrder", testMatchOrder},
+		{"MatchOrderExpiry", testMatchOrderExpiry},
+		{"ExecuteOrder", testExecuteOrder},
+		{"ExecuteImmediateOrCancelOrder", testExecuteImmediateOrCancelOrder},
+		{"ExecuteFillOrKillOrder", testExecuteFillOrKillOrder},
+		{"ExecuteMarketOrder", testExecuteMarketOrder},
+		{"StopOrders", testStopOrders},
+		{"IcebergOrders", testIcebergOrders},
+		{"PostOnlyOrders", testPostOnlyOrders},
+		{"HiddenOrders", testHiddenOrders},
+		{"MarketDataSnapshot", testMarketDataSnapshot},
+		{"BestBidOffer", testBestBidOffer},
+		{"RecordTrade", testRecordTrade},
+		{"Trades", testTrades},
+		{"Instruments", testInstruments},
+		{"InstrumentOrders", testInstrumentOrders},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			store, teardown := newStore(t)
+			defer teardown()
+
+			c.run(t, store)
+		})
+	}
+}
+
+// conformanceOrder creates an order of the instrument and stores it unless it is to be executed
+func conformanceOrder(
+	t *testing.T,
+	store datastore.DataStore,
+	constructor func(*models.OrderGeneralInfo) (*models.Order, error),
+	info models.OrderGeneralInfo,
+	isStored bool,
+) *models.Order {
+	t.Helper()
+
+	order, err := constructor(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isStored {
+		if err := store.CreateOrder(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return order
+}
+
+func restingOrder(t *testing.T, store datastore.DataStore, tradeCode uuid.UUID, operation models.MarketOperation, price int64, quantity uint) *models.Order {
+	t.Helper()
+
+	return conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(price),
+		Quantity:     quantity,
+		Operation:    operation,
+		CounterParty: string(operation) + "CounterParty",
+	}, true)
+}
+
+// expiryDelay is how long an order expiring in a case stays valid, since expired orders are never stored
+const expiryDelay = time.Second
+
+func waitUntil(moment time.Time) {
+	time.Sleep(time.Until(moment) + time.Millisecond)
+}
+
+func matchingIDs(t *testing.T, store datastore.DataStore, order *models.Order) []uuid.UUID {
+	t.Helper()
+
+	matchingOrders, err := store.MatchOrder(context.Background(), order)
+	assert.Nil(t, err)
+
+	ids := make([]uuid.UUID, 0, len(matchingOrders))
+	for _, matchingOrder := range matchingOrders {
+		ids = append(ids, matchingOrder.ID)
+	}
+	return ids
+}
+
+func incomingOrder(tradeCode uuid.UUID, operation models.MarketOperation, price int64) *models.Order {
+	return &models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{
+			TradeCode: tradeCode,
+			Price:     decimal.NewFromInt(price),
+			Operation: operation,
+		},
+	}
+}
+
+func testCreateOrder(t *testing.T, store datastore.DataStore) {
+	assert.Equal(t, datastore.ErrEmptyStruct, store.CreateOrder(context.Background(), nil))
+	assert.Equal(t, datastore.ErrZeroID, store.CreateOrder(context.Background(), &models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{},
+	}))
+
+	// Anything but a known operation is rejected instead of landing on a side of the book
+	unknown, _ := models.NewGoodTillCancelledOrder(&models.OrderGeneralInfo{
+		TradeCode:    uuid.New(),
+		Price:        decimal.NewFromInt(32),
+		Quantity:     5,
+		Operation:    "sell",
+		CounterParty: "SellCounterParty",
+	})
+	assert.Equal(t, datastore.ErrUnknownOperation, store.CreateOrder(context.Background(), unknown))
+	_, err := store.OrderByID(context.Background(), unknown.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	bid := restingOrder(t, store, uuid.New(), models.Bid, 32, 5)
+	assert.NotZero(t, bid.Sequence)
+	assert.False(t, bid.CreatedAt.IsZero())
+
+	bidFromStore, err := store.OrderByID(context.Background(), bid.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, bid.ID, bidFromStore.ID)
+	assert.Equal(t, bid.TradeCode, bidFromStore.TradeCode)
+	assert.True(t, bid.Price.Equal(bidFromStore.Price))
+	assert.Equal(t, bid.Quantity, bidFromStore.Quantity)
+	assert.Equal(t, bid.Operation, bidFromStore.Operation)
+	assert.Equal(t, bid.CounterParty, bidFromStore.CounterParty)
+	assert.Equal(t, bid.Sequence, bidFromStore.Sequence)
+	assert.Equal(t, bid.Type, bidFromStore.Type)
+	assert.Equal(t, bid.Kind, bidFromStore.Kind)
+	assert.Equal(t, models.New, bidFromStore.Status)
+	assert.True(t, bid.ValidUntil.Equal(*bidFromStore.ValidUntil))
+
+	// Later arrivals get later sequence
+	ask := restingOrder(t, store, uuid.New(), models.Ask, 21, 3)
+	assert.Greater(t, ask.Sequence, bid.Sequence)
+}
+
+func testCreateOrders(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	results, err := store.CreateOrders(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	bid := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(32),
+		Quantity:     5,
+		Operation:    models.Bid,
+		CounterParty: "BidCounterParty",
+	}, false)
+	ask := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(33),
+		Quantity:     3,
+		Operation:    models.Ask,
+		CounterParty: "AskCounterParty",
+	}, false)
+	zeroID := &models.Order{OrderGeneralInfo: &models.OrderGeneralInfo{TradeCode: tradeCode}}
+	zeroQuantity := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(33),
+		Operation:    models.Ask,
+		CounterParty: "AskCounterParty",
+	}, false)
+
+	// Invalid orders are rejected one by one without failing the batch
+	results, err = store.CreateOrders(context.Background(), []*models.Order{bid, nil, zeroID, zeroQuantity, ask})
+	assert.Nil(t, err)
+	assert.Equal(t, []error{nil, datastore.ErrEmptyStruct, datastore.ErrZeroID, datastore.ErrZeroQuantity, nil}, results)
+
+	// Batch keeps arrival order
+	assert.NotZero(t, bid.Sequence)
+	assert.Greater(t, ask.Sequence, bid.Sequence)
+	assert.False(t, ask.CreatedAt.IsZero())
+
+	for _, order := range []*models.Order{bid, ask} {
+		orderFromStore, err := store.OrderByID(context.Background(), order.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, order.Sequence, orderFromStore.Sequence)
+		assert.Equal(t, order.Quantity, orderFromStore.Quantity)
+		assert.True(t, order.Price.Equal(orderFromStore.Price))
+	}
+
+	snapshot, err := store.MarketDataSnapshot(context.Background(), tradeCode, 10)
+	assert.Nil(t, err)
+	assert.Len(t, snapshot.Bids, 1)
+	assert.Len(t, snapshot.Asks, 1)
+}
+
+func testDisableOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	assert.Equal(t, datastore.ErrZeroID, store.DisableOrder(context.Background(), uuid.Nil))
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, store.DisableOrder(context.Background(), uuid.New()))
+
+	ask := restingOrder(t, store, tradeCode, models.Ask, 21, 3)
+	assert.Nil(t, store.DisableOrder(context.Background(), ask.ID))
+
+	// Cancellation is visible to the very next read
+	_, err := store.OrderByID(context.Background(), ask.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+	assert.Empty(t, matchingIDs(t, store, incomingOrder(tradeCode, models.Bid, 21)))
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, marketData.Asks)
+
+	// Disabling twice is fine
+	assert.Nil(t, store.DisableOrder(context.Background(), ask.ID))
+
+	// Filled order is not cancelled
+	filledAsk := restingOrder(t, store, tradeCode, models.Ask, 22, 1)
+	trades, err := store.ExecuteOrder(context.Background(), conformanceOrder(t, store, models.NewImmediateOrCancelOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(22),
+		Quantity:     1,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false))
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+
+	assert.Nil(t, store.DisableOrder(context.Background(), filledAsk.ID))
+	askFromStore, err := store.OrderByID(context.Background(), filledAsk.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Filled, askFromStore.Status)
+
+	// Order built without a constructor is opened on arrival and cancelled as any other
+	validUntil := time.Now().UTC().Add(time.Hour)
+	literalBid := &models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{
+			ID:           uuid.New(),
+			TradeCode:    tradeCode,
+			ValidUntil:   &validUntil,
+			Price:        decimal.NewFromInt(20),
+			Quantity:     4,
+			Operation:    models.Bid,
+			CounterParty: "literalBid",
+			IsEnabled:    true,
+		},
+		Type: models.GoodTillCancelled,
+	}
+	assert.Nil(t, store.CreateOrder(context.Background(), literalBid))
+
+	bidFromStore, err := store.OrderByID(context.Background(), literalBid.ID)
+	assert.Nil(t, err)
+	if err == nil {
+		assert.Equal(t, models.New, bidFromStore.Status)
+		assert.Equal(t, uint(4), bidFromStore.OriginalQuantity)
+	}
+
+	assert.Nil(t, store.DisableOrder(context.Background(), literalBid.ID))
+	_, err = store.OrderByID(context.Background(), literalBid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+}
+
+func testAmendOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	price := decimal.NewFromInt(100)
+
+	assert.Equal(t, datastore.ErrZeroID, store.AmendOrder(context.Background(), uuid.Nil, price, 1))
+	assert.Equal(t, datastore.ErrNonPositivePrice, store.AmendOrder(context.Background(), uuid.New(), decimal.Zero, 1))
+	assert.Equal(t, datastore.ErrNonPositivePrice, store.AmendOrder(context.Background(), uuid.New(), price.Neg(), 1))
+	assert.Equal(t, datastore.ErrZeroQuantity, store.AmendOrder(context.Background(), uuid.New(), price, 0))
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, store.AmendOrder(context.Background(), uuid.New(), price, 1))
+
+	firstAsk := restingOrder(t, store, tradeCode, models.Ask, 100, 5)
+	secondAsk := restingOrder(t, store, tradeCode, models.Ask, 100, 5)
+	anyBid := incomingOrder(tradeCode, models.Bid, 1000)
+
+	// Quantity reduction keeps time priority
+	assert.Nil(t, store.AmendOrder(context.Background(), firstAsk.ID, price, 3))
+	askFromStore, err := store.OrderByID(context.Background(), firstAsk.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, firstAsk.ID, askFromStore.ID)
+	assert.Equal(t, uint(3), askFromStore.Quantity)
+	assert.Equal(t, uint(3), askFromStore.OriginalQuantity)
+	assert.Equal(t, []uuid.UUID{firstAsk.ID, secondAsk.ID}, matchingIDs(t, store, anyBid))
+
+	// Quantity increase loses it
+	assert.Nil(t, store.AmendOrder(context.Background(), firstAsk.ID, price, 4))
+	assert.Equal(t, []uuid.UUID{secondAsk.ID, firstAsk.ID}, matchingIDs(t, store, anyBid))
+
+	// Price change loses it even when the price is restored
+	assert.Nil(t, store.AmendOrder(context.Background(), secondAsk.ID, decimal.NewFromInt(101), 5))
+	assert.Nil(t, store.AmendOrder(context.Background(), secondAsk.ID, price, 5))
+	askFromStore, _ = store.OrderByID(context.Background(), secondAsk.ID)
+	assert.True(t, price.Equal(askFromStore.Price))
+	assert.Equal(t, []uuid.UUID{firstAsk.ID, secondAsk.ID}, matchingIDs(t, store, anyBid))
+
+	// Amendment doesn't execute, so it can't cross the book
+	restingOrder(t, store, tradeCode, models.Bid, 90, 5)
+	assert.Equal(t, datastore.ErrCrossingAmend, store.AmendOrder(context.Background(), secondAsk.ID, decimal.NewFromInt(90), 5))
+	askFromStore, _ = store.OrderByID(context.Background(), secondAsk.ID)
+	assert.True(t, price.Equal(askFromStore.Price))
+
+	bestBidOffer, err := store.BestBidOffer(context.Background(), tradeCode)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(10).Equal(*bestBidOffer.Spread))
+
+	// Cancelled orders can't be amended
+	assert.Nil(t, store.DisableOrder(context.Background(), firstAsk.ID))
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, store.AmendOrder(context.Background(), firstAsk.ID, price, 1))
+}
+
+func testOrderByID(t *testing.T, store datastore.DataStore) {
+	_, err := store.OrderByID(context.Background(), uuid.Nil)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	_, err = store.OrderByID(context.Background(), uuid.New())
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	// Expired orders are not returned
+	expiredAt := time.Now().UTC().Add(expiryDelay)
+	expiredBid := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    uuid.New(),
+		Price:        decimal.NewFromInt(10),
+		Quantity:     1,
+		Operation:    models.Bid,
+		CounterParty: "expiredBid",
+		ValidUntil:   &expiredAt,
+	}, true)
+	waitUntil(expiredAt)
+
+	_, err = store.OrderByID(context.Background(), expiredBid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	// Orders which are not processable anymore are found with their actual status
+	_, err = store.FindOrder(context.Background(), uuid.Nil)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	_, err = store.FindOrder(context.Background(), uuid.New())
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	assertFoundStatus := func(id uuid.UUID, expected models.OrderStatus) {
+		t.Helper()
+
+		order, err := store.FindOrder(context.Background(), id)
+		assert.Nil(t, err)
+		if err == nil {
+			assert.Equal(t, id, order.ID)
+			assert.Equal(t, expected, order.Status)
+		}
+	}
+
+	assertFoundStatus(expiredBid.ID, models.Expired)
+
+	tradeCode := uuid.New()
+	ask := restingOrder(t, store, tradeCode, models.Ask, 10, 1)
+	assertFoundStatus(ask.ID, models.New)
+
+	assert.Nil(t, store.DisableOrder(context.Background(), ask.ID))
+	assertFoundStatus(ask.ID, models.Cancelled)
+
+	filledAsk := restingOrder(t, store, tradeCode, models.Ask, 11, 1)
+	trades, err := store.ExecuteOrder(context.Background(), conformanceOrder(t, store, models.NewImmediateOrCancelOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(11),
+		Quantity:     1,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false))
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assertFoundStatus(filledAsk.ID, models.Filled)
+}
+
+func testMatchOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	_, err := store.MatchOrder(context.Background(), nil)
+	assert.Equal(t, datastore.ErrEmptyStruct, err)
+
+	_, err = store.MatchOrder(context.Background(), &models.Order{})
+	assert.Equal(t, datastore.ErrEmptyStruct, err)
+
+	_, err = store.MatchOrder(context.Background(), incomingOrder(tradeCode, "sell", 100))
+	assert.Equal(t, datastore.ErrUnknownOperation, err)
+
+	expensiveAsk := restingOrder(t, store, tradeCode, models.Ask, 110, 1)
+	firstCheapAsk := restingOrder(t, store, tradeCode, models.Ask, 100, 1)
+	secondCheapAsk := restingOrder(t, store, tradeCode, models.Ask, 100, 1)
+	restingOrder(t, store, tradeCode, models.Ask, 200, 1)
+	restingOrder(t, store, uuid.New(), models.Ask, 100, 1)
+
+	// Best price first, then the earliest arrival, other instruments are never matched.
+	// Repeated to make sure no iteration order leaks into results
+	for i := 0; i < 10; i++ {
+		assert.Equal(
+			t,
+			[]uuid.UUID{firstCheapAsk.ID, secondCheapAsk.ID, expensiveAsk.ID},
+			matchingIDs(t, store, incomingOrder(tradeCode, models.Bid, 150)),
+		)
+	}
+	assert.Empty(t, matchingIDs(t, store, incomingOrder(tradeCode, models.Bid, 99)))
+
+	cheapBid := restingOrder(t, store, tradeCode, models.Bid, 50, 1)
+	firstExpensiveBid := restingOrder(t, store, tradeCode, models.Bid, 60, 1)
+	secondExpensiveBid := restingOrder(t, store, tradeCode, models.Bid, 60, 1)
+	restingOrder(t, store, tradeCode, models.Bid, 10, 1)
+
+	assert.Equal(
+		t,
+		[]uuid.UUID{firstExpensiveBid.ID, secondExpensiveBid.ID, cheapBid.ID},
+		matchingIDs(t, store, incomingOrder(tradeCode, models.Ask, 50)),
+	)
+}
+
+func testMatchOrderExpiry(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	// Both orders expire within the same day, only the time of day differs
+	validUntil := time.Now().UTC().Add(time.Minute)
+	validAsk := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     1,
+		Operation:    models.Ask,
+		CounterParty: "validAsk",
+		ValidUntil:   &validUntil,
+	}, true)
+	expiredAt := time.Now().UTC().Add(expiryDelay)
+	conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     1,
+		Operation:    models.Ask,
+		CounterParty: "expiredAsk",
+		ValidUntil:   &expiredAt,
+	}, true)
+	waitUntil(expiredAt)
+
+	matchingAsks, err := store.MatchOrder(context.Background(), incomingOrder(tradeCode, models.Bid, 100))
+	assert.Nil(t, err)
+	assert.Len(t, matchingAsks, 1)
+	assert.Equal(t, validAsk.ID, matchingAsks[0].ID)
+	assert.True(t, validUntil.Equal(*matchingAsks[0].ValidUntil))
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 1)
+	assert.Equal(t, uint(1), marketData.Asks[0].Orders)
+
+	// One-day order is valid until the session close
+	oneDayAsk := conformanceOrder(t, store, func(info *models.OrderGeneralInfo) (*models.Order, error) {
+		return models.NewOneDayOrder(info, models.DefaultTradingSession)
+	}, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(90),
+		Quantity:     1,
+		Operation:    models.Ask,
+		CounterParty: "oneDayAsk",
+	}, true)
+
+	matchingAsks, err = store.MatchOrder(context.Background(), incomingOrder(tradeCode, models.Bid, 90))
+	assert.Nil(t, err)
+	assert.Len(t, matchingAsks, 1)
+	assert.Equal(t, oneDayAsk.ID, matchingAsks[0].ID)
+	assert.Equal(t, models.OneDay, matchingAsks[0].Type)
+	assert.True(t, oneDayAsk.ValidUntil.Equal(*matchingAsks[0].ValidUntil))
+}
+
+func testExecuteOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	_, err := store.ExecuteOrder(context.Background(), nil)
+	assert.Equal(t, datastore.ErrEmptyStruct, err)
+
+	_, err = store.ExecuteOrder(context.Background(), &models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{},
+	})
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	cheapAsk := restingOrder(t, store, tradeCode, models.Ask, 100, 3)
+	expensiveAsk := restingOrder(t, store, tradeCode, models.Ask, 110, 5)
+	unreachableAsk := restingOrder(t, store, tradeCode, models.Ask, 200, 5)
+
+	bid := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(150),
+		Quantity:     5,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false)
+
+	trades, err := store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 2)
+	assert.Equal(t, bid.ID, trades[0].BuyOrderID)
+	assert.Equal(t, cheapAsk.ID, trades[0].SellOrderID)
+	assert.True(t, decimal.NewFromInt(100).Equal(trades[0].Price))
+	assert.Equal(t, uint(3), trades[0].Quantity)
+	assert.Equal(t, models.Bid, trades[0].Aggressor)
+	assert.Equal(t, bid.ID, trades[1].BuyOrderID)
+	assert.Equal(t, expensiveAsk.ID, trades[1].SellOrderID)
+	assert.True(t, decimal.NewFromInt(110).Equal(trades[1].Price))
+	assert.Equal(t, uint(2), trades[1].Quantity)
+
+	tape, err := store.Trades(context.Background(), models.TradeFilter{TradeCode: tradeCode})
+	assert.Nil(t, err)
+	assert.Len(t, tape, 2)
+	for i := range tape {
+		assertSameTrade(t, trades[i], tape[i])
+	}
+
+	assert.Zero(t, bid.Quantity)
+	assert.Equal(t, uint(5), bid.FilledQuantity)
+	assert.True(t, decimal.NewFromInt(104).Equal(bid.AverageFillPrice))
+	assert.Equal(t, models.Filled, bid.Status)
+	_, err = store.OrderByID(context.Background(), bid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	// Fills are visible to the very next read
+	askFromStore, _ := store.OrderByID(context.Background(), cheapAsk.ID)
+	assert.Zero(t, askFromStore.Quantity)
+	assert.Equal(t, models.Filled, askFromStore.Status)
+	askFromStore, _ = store.OrderByID(context.Background(), expensiveAsk.ID)
+	assert.Equal(t, uint(3), askFromStore.Quantity)
+	assert.Equal(t, uint(2), askFromStore.FilledQuantity)
+	assert.Equal(t, uint(5), askFromStore.OriginalQuantity)
+	assert.Equal(t, models.PartiallyFilled, askFromStore.Status)
+	askFromStore, _ = store.OrderByID(context.Background(), unreachableAsk.ID)
+	assert.Equal(t, uint(5), askFromStore.Quantity)
+	assert.Equal(t, models.New, askFromStore.Status)
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 2)
+	assert.Equal(t, uint(3), marketData.Asks[0].Quantity)
+
+	// Remainder rests in the book
+	ask := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(300),
+		Quantity:     4,
+		Operation:    models.Ask,
+		CounterParty: "ask",
+	}, false)
+
+	trades, err = store.ExecuteOrder(context.Background(), ask)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+
+	askFromStore, err = store.OrderByID(context.Background(), ask.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(4), askFromStore.Quantity)
+}
+
+func testExecuteImmediateOrCancelOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+
+	bid := conformanceOrder(t, store, models.NewImmediateOrCancelOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     5,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false)
+
+	trades, err := store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, uint(2), trades[0].Quantity)
+	assert.Equal(t, uint(3), bid.Quantity)
+	assert.Equal(t, uint(2), bid.FilledQuantity)
+	assert.Equal(t, models.Cancelled, bid.Status)
+
+	// Remainder is cancelled instead of resting in the book
+	_, err = store.OrderByID(context.Background(), bid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+}
+
+func testExecuteFillOrKillOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	ask := restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+
+	tooBigBid := conformanceOrder(t, store, models.NewFillOrKillOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     3,
+		Operation:    models.Bid,
+		CounterParty: "tooBigBid",
+	}, false)
+
+	trades, err := store.ExecuteOrder(context.Background(), tooBigBid)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+	assert.Equal(t, uint(3), tooBigBid.Quantity)
+	assert.Equal(t, models.Cancelled, tooBigBid.Status)
+
+	// The book is left untouched
+	_, err = store.OrderByID(context.Background(), tooBigBid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+	askFromStore, _ := store.OrderByID(context.Background(), ask.ID)
+	assert.Equal(t, uint(2), askFromStore.Quantity)
+
+	bid := conformanceOrder(t, store, models.NewFillOrKillOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     2,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false)
+
+	trades, err = store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Zero(t, bid.Quantity)
+	askFromStore, _ = store.OrderByID(context.Background(), ask.ID)
+	assert.Zero(t, askFromStore.Quantity)
+}
+
+func testExecuteMarketOrder(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	restingOrder(t, store, tradeCode, models.Ask, 100, 3)
+	restingOrder(t, store, tradeCode, models.Ask, 110, 5)
+	restingOrder(t, store, tradeCode, models.Ask, 120, 2)
+
+	marketOrder := func(operation models.MarketOperation, protection int64, quantity uint) *models.Order {
+		return conformanceOrder(t, store, models.NewMarketOrder, models.OrderGeneralInfo{
+			TradeCode:    tradeCode,
+			Price:        decimal.NewFromInt(protection),
+			Quantity:     quantity,
+			Operation:    operation,
+			CounterParty: string(operation) + "Market",
+		}, false)
+	}
+
+	assert.Equal(t, datastore.ErrRestingMarket, store.CreateOrder(context.Background(), marketOrder(models.Bid, 0, 1)))
+
+	// Best prices are taken first whatever they are
+	bid := marketOrder(models.Bid, 0, 7)
+	trades, err := store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 2)
+	assert.True(t, decimal.NewFromInt(100).Equal(trades[0].Price))
+	assert.Equal(t, uint(3), trades[0].Quantity)
+	assert.True(t, decimal.NewFromInt(110).Equal(trades[1].Price))
+	assert.Equal(t, uint(4), trades[1].Quantity)
+	assert.Equal(t, models.Filled, bid.Status)
+
+	// Protection limit stops the sweep and the remainder is cancelled
+	protectedBid := marketOrder(models.Bid, 115, 10)
+	trades, err = store.ExecuteOrder(context.Background(), protectedBid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, uint(1), trades[0].Quantity)
+	assert.Equal(t, uint(9), protectedBid.Quantity)
+	assert.Equal(t, models.Cancelled, protectedBid.Status)
+
+	_, err = store.OrderByID(context.Background(), protectedBid.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+
+	// Market order may be fill-or-kill as well
+	killedBid := marketOrder(models.Bid, 0, 5)
+	killedBid.Type = models.FillOrKill
+	trades, err = store.ExecuteOrder(context.Background(), killedBid)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+	assert.Equal(t, models.Cancelled, killedBid.Status)
+
+	// Exhausted book leaves the rest unfilled
+	sweepingBid := marketOrder(models.Bid, 0, 5)
+	trades, err = store.ExecuteOrder(context.Background(), sweepingBid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.True(t, decimal.NewFromInt(120).Equal(trades[0].Price))
+	assert.Equal(t, uint(2), sweepingBid.FilledQuantity)
+	assert.Equal(t, models.Cancelled, sweepingBid.Status)
+
+	ask := marketOrder(models.Ask, 0, 1)
+	trades, err = store.ExecuteOrder(context.Background(), ask)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+	assert.Equal(t, models.Cancelled, ask.Status)
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, marketData.Asks)
+	assert.Empty(t, marketData.Bids)
+}
+
+func testStopOrders(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	_, err := store.LastTradePrice(context.Background(), uuid.Nil)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	_, err = store.LastTradePrice(context.Background(), tradeCode)
+	assert.Equal(t, datastore.ErrNoLastTradePrice, err)
+
+	restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+	restingOrder(t, store, tradeCode, models.Ask, 105, 2)
+	restingOrder(t, store, tradeCode, models.Ask, 110, 5)
+	restingBid := restingOrder(t, store, tradeCode, models.Bid, 90, 5)
+
+	stopOrder := func(
+		constructor func(*models.OrderGeneralInfo) (*models.Order, error),
+		operation models.MarketOperation,
+		stopPrice, price int64,
+		quantity uint,
+	) *models.Order {
+		return conformanceOrder(t, store, constructor, models.OrderGeneralInfo{
+			TradeCode:    tradeCode,
+			Price:        decimal.NewFromInt(price),
+			StopPrice:    decimal.NewFromInt(stopPrice),
+			Quantity:     quantity,
+			Operation:    operation,
+			CounterParty: string(operation) + "Stop",
+		}, false)
+	}
+
+	assert.Equal(t, datastore.ErrRestingStop, store.CreateOrder(context.Background(), stopOrder(models.NewStopLimitOrder, models.Bid, 100, 101, 1)))
+
+	_, err = store.ExecuteOrder(context.Background(), stopOrder(models.NewStopMarketOrder, models.Bid, 0, 0, 1))
+	assert.Equal(t, datastore.ErrNonPositiveStop, err)
+
+	// Stop orders wait in the trigger book out of matching and market data
+	stopMarket := stopOrder(models.NewStopMarketOrder, models.Bid, 104, 0, 2)
+	trades, err := store.ExecuteOrder(context.Background(), stopMarket)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+
+	stopLimit := stopOrder(models.NewStopLimitOrder, models.Bid, 100, 106, 5)
+	trades, err = store.ExecuteOrder(context.Background(), stopLimit)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+
+	stopFromStore, err := store.OrderByID(context.Background(), stopLimit.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, models.StopLimit, stopFromStore.Kind)
+	assert.Equal(t, models.GoodTillCancelled, stopFromStore.Type)
+	assert.True(t, decimal.NewFromInt(100).Equal(stopFromStore.StopPrice))
+
+	assert.Equal(t, []uuid.UUID{restingBid.ID}, matchingIDs(t, store, incomingOrder(tradeCode, models.Ask, 1)))
+
+	// Every execution may trigger further stops, they go in arrival order of the triggered ones
+	bid := conformanceOrder(t, store, models.NewMarketOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Quantity:     2,
+		Operation:    models.Bid,
+		CounterParty: "BidMarket",
+	}, false)
+	trades, err = store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 3)
+
+	expectedTrades := []struct {
+		buyOrderID uuid.UUID
+		price      int64
+		quantity   uint
+	}{
+		{bid.ID, 100, 2},
+		{stopLimit.ID, 105, 2},
+		{stopMarket.ID, 110, 2},
+	}
+	for i, expected := range expectedTrades {
+		if i >= len(trades) {
+			break
+		}
+		assert.Equal(t, expected.buyOrderID, trades[i].BuyOrderID)
+		assert.True(t, decimal.NewFromInt(expected.price).Equal(trades[i].Price))
+		assert.Equal(t, expected.quantity, trades[i].Quantity)
+	}
+
+	lastPrice, err := store.LastTradePrice(context.Background(), tradeCode)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(110).Equal(lastPrice))
+
+	// Triggered stop limit rests as a limit order
+	triggered, err := store.OrderByID(context.Background(), stopLimit.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Limit, triggered.Kind)
+	assert.Equal(t, models.GoodTillCancelled, triggered.Type)
+	assert.Equal(t, uint(3), triggered.Quantity)
+
+	// Filled stop is kept as any other filled order
+	filledStop, err := store.OrderByID(context.Background(), stopMarket.ID)
+	assert.Nil(t, err)
+	if err == nil {
+		assert.Equal(t, models.Market, filledStop.Kind)
+		assert.Equal(t, models.Filled, filledStop.Status)
+		assert.Zero(t, filledStop.Quantity)
+	}
+
+	// Stop triggered into an empty book is cancelled and still known to the store
+	emptyTradeCode := uuid.New()
+	lonelyStop := conformanceOrder(t, store, models.NewStopMarketOrder, models.OrderGeneralInfo{
+		TradeCode:    emptyTradeCode,
+		StopPrice:    decimal.NewFromInt(50),
+		Quantity:     1,
+		Operation:    models.Bid,
+		CounterParty: "BidStop",
+	}, false)
+	trades, err = store.ExecuteOrder(context.Background(), lonelyStop)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+
+	emptyBookTrade := conformanceTrade(emptyTradeCode, "recorded", time.Now().UTC())
+	emptyBookTrade.Price = decimal.NewFromInt(50)
+	assert.Nil(t, store.RecordTrade(context.Background(), &emptyBookTrade))
+
+	_, err = store.OrderByID(context.Background(), lonelyStop.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+	assert.Nil(t, store.DisableOrder(context.Background(), lonelyStop.ID))
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Bids, 2)
+	assert.True(t, decimal.NewFromInt(106).Equal(marketData.Bids[0].Price))
+	assert.Equal(t, uint(3), marketData.Bids[0].Quantity)
+	assert.Len(t, marketData.Asks, 1)
+	assert.Equal(t, uint(3), marketData.Asks[0].Quantity)
+
+	// Recorded trades move the last trade price too
+	sellStop := stopOrder(models.NewStopMarketOrder, models.Ask, 95, 0, 1)
+	trades, err = store.ExecuteOrder(context.Background(), sellStop)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+
+	recorded := conformanceTrade(tradeCode, "recorded", time.Now().UTC())
+	recorded.Price = decimal.NewFromInt(95)
+	assert.Nil(t, store.RecordTrade(context.Background(), &recorded))
+
+	trades, err = store.Trades(context.Background(), models.TradeFilter{TradeCode: tradeCode, CounterParty: sellStop.CounterParty})
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	if len(trades) == 1 {
+		assert.True(t, decimal.NewFromInt(106).Equal(trades[0].Price))
+	}
+
+	// Stop order already reached by the last trade price is executed right away
+	sellStopLimit := stopOrder(models.NewStopLimitOrder, models.Ask, 120, 106, 1)
+	trades, err = store.ExecuteOrder(context.Background(), sellStopLimit)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, models.Filled, sellStopLimit.Status)
+
+	// Triggered stop keeps its type, so immediate-or-cancel stop limit never rests
+	cancelledStopLimit := stopOrder(models.NewStopLimitOrder, models.Bid, 100, 110, 5)
+	cancelledStopLimit.Type = models.ImmediateOrCancel
+	trades, err = store.ExecuteOrder(context.Background(), cancelledStopLimit)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, uint(3), cancelledStopLimit.FilledQuantity)
+	assert.Equal(t, models.Cancelled, cancelledStopLimit.Status)
+
+	_, err = store.OrderByID(context.Background(), cancelledStopLimit.ID)
+	assert.Equal(t, datastore.ErrOrderDoesNotExist, err)
+}
+
+func testIcebergOrders(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	icebergInfo := models.OrderGeneralInfo{
+		TradeCode:       tradeCode,
+		Price:           decimal.NewFromInt(100),
+		Quantity:        10,
+		Operation:       models.Ask,
+		CounterParty:    "icebergAsk",
+		DisplayQuantity: 11,
+	}
+
+	tooBigPeak, err := models.NewGoodTillCancelledOrder(&icebergInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, datastore.ErrDisplayQuantity, store.CreateOrder(context.Background(), tooBigPeak))
+
+	icebergInfo.DisplayQuantity = 3
+	iceberg := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, icebergInfo, true)
+	ask := restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+
+	// Only the peak is displayed
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 1)
+	assert.True(t, decimal.NewFromInt(100).Equal(marketData.Asks[0].Price))
+	assert.Equal(t, uint(5), marketData.Asks[0].Quantity)
+	assert.Equal(t, uint(2), marketData.Asks[0].Orders)
+
+	bid := func(quantity uint) []models.Trade {
+		trades, err := store.ExecuteOrder(
+			context.Background(),
+			conformanceOrder(t, store, models.NewImmediateOrCancelOrder, models.OrderGeneralInfo{
+				TradeCode:    tradeCode,
+				Price:        decimal.NewFromInt(100),
+				Quantity:     quantity,
+				Operation:    models.Bid,
+				CounterParty: "bid",
+			}, false),
+		)
+		assert.Nil(t, err)
+		return trades
+	}
+
+	// Refreshed peak loses time priority to the orders of its level
+	trades := bid(4)
+	assert.Len(t, trades, 2)
+	assert.Equal(t, iceberg.ID, trades[0].SellOrderID)
+	assert.Equal(t, uint(3), trades[0].Quantity)
+	assert.Equal(t, ask.ID, trades[1].SellOrderID)
+	assert.Equal(t, uint(1), trades[1].Quantity)
+
+	trades = bid(2)
+	assert.Len(t, trades, 2)
+	assert.Equal(t, ask.ID, trades[0].SellOrderID)
+	assert.Equal(t, iceberg.ID, trades[1].SellOrderID)
+	assert.Equal(t, uint(1), trades[1].Quantity)
+
+	icebergFromStore, err := store.OrderByID(context.Background(), iceberg.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(6), icebergFromStore.Quantity)
+	assert.Equal(t, uint(2), icebergFromStore.PeakQuantity)
+
+	marketData, err = store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 1)
+	assert.Equal(t, uint(2), marketData.Asks[0].Quantity)
+	assert.Equal(t, uint(1), marketData.Asks[0].Orders)
+
+	// Reserve replenishes the peak until it is exhausted
+	trades = bid(10)
+	assert.Len(t, trades, 3)
+	for i, quantity := range []uint{2, 3, 1} {
+		assert.Equal(t, iceberg.ID, trades[i].SellOrderID)
+		assert.Equal(t, quantity, trades[i].Quantity)
+	}
+
+	marketData, err = store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, marketData.Asks)
+}
+
+func testPostOnlyOrders(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	ask := restingOrder(t, store, tradeCode, models.Ask, 100, 10)
+
+	postOnlyBid := func(tradeCode uuid.UUID, price string, mode models.PostOnlyMode) *models.Order {
+		return conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+			TradeCode:    tradeCode,
+			Price:        decimal.RequireFromString(price),
+			Quantity:     10,
+			Operation:    models.Bid,
+			CounterParty: "postOnlyBid",
+			PostOnly:     mode,
+		}, false)
+	}
+
+	// Crossing order is rejected instead of taking liquidity
+	assert.Equal(t, datastore.ErrPostOnlyCross, store.CreateOrder(context.Background(), postOnlyBid(tradeCode, "100", models.PostOnlyReject)))
+
+	trades, err := store.ExecuteOrder(context.Background(), postOnlyBid(tradeCode, "101", models.PostOnlyReject))
+	assert.Equal(t, datastore.ErrPostOnlyCross, err)
+	assert.Empty(t, trades)
+
+	askFromStore, err := store.OrderByID(context.Background(), ask.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(10), askFromStore.Quantity)
+
+	// Repricing needs the instrument tick size
+	assert.Equal(t, datastore.ErrPostOnlyCross, store.CreateOrder(context.Background(), postOnlyBid(tradeCode, "100", models.PostOnlyReprice)))
+	assert.Nil(t, store.CreateOrder(context.Background(), postOnlyBid(tradeCode, "99", models.PostOnlyReject)))
+
+	instrument := conformanceInstrument()
+	assert.Nil(t, store.SaveInstrument(context.Background(), instrument))
+	restingOrder(t, store, instrument.TradeCode, models.Ask, 100, 10)
+
+	repriced := postOnlyBid(instrument.TradeCode, "100.5", models.PostOnlyReprice)
+	trades, err = store.ExecuteOrder(context.Background(), repriced)
+	assert.Nil(t, err)
+	assert.Empty(t, trades)
+	assert.True(t, decimal.RequireFromString("99.95").Equal(repriced.Price))
+
+	repricedFromStore, err := store.OrderByID(context.Background(), repriced.ID)
+	assert.Nil(t, err)
+	assert.True(t, decimal.RequireFromString("99.95").Equal(repricedFromStore.Price))
+
+	results, err := store.CreateOrders(context.Background(), []*models.Order{
+		postOnlyBid(instrument.TradeCode, "100", models.PostOnlyReject),
+		postOnlyBid(instrument.TradeCode, "99.9", models.PostOnlyReject),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []error{datastore.ErrPostOnlyCross, nil}, results)
+
+	// Orders accepted earlier in the batch are taken into account
+	batchTradeCode := uuid.New()
+	results, err = store.CreateOrders(context.Background(), []*models.Order{
+		conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+			TradeCode:    batchTradeCode,
+			Price:        decimal.NewFromInt(10),
+			Quantity:     10,
+			Operation:    models.Ask,
+			CounterParty: "askCounterParty",
+		}, false),
+		postOnlyBid(batchTradeCode, "11", models.PostOnlyReject),
+		postOnlyBid(batchTradeCode, "9", models.PostOnlyReject),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []error{nil, datastore.ErrPostOnlyCross, nil}, results)
+
+	bbo, err := store.BestBidOffer(context.Background(), batchTradeCode)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(9).Equal(bbo.Bid.Price))
+	assert.True(t, decimal.NewFromInt(10).Equal(bbo.Ask.Price))
+
+	// Amendment to a crossing price follows the post-only mode as well
+	rejected := postOnlyBid(tradeCode, "98", models.PostOnlyReject)
+	assert.Nil(t, store.CreateOrder(context.Background(), rejected))
+	assert.Equal(t, datastore.ErrPostOnlyCross, store.AmendOrder(context.Background(), rejected.ID, decimal.NewFromInt(100), 10))
+
+	rejectedFromStore, err := store.OrderByID(context.Background(), rejected.ID)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(98).Equal(rejectedFromStore.Price))
+
+	assert.Nil(t, store.AmendOrder(context.Background(), repriced.ID, decimal.NewFromInt(101), 10))
+	repricedFromStore, err = store.OrderByID(context.Background(), repriced.ID)
+	assert.Nil(t, err)
+	assert.True(t, decimal.RequireFromString("99.95").Equal(repricedFromStore.Price))
+}
+
+func testHiddenOrders(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	hidden := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     5,
+		Operation:    models.Ask,
+		CounterParty: "hiddenAsk",
+		IsHidden:     true,
+	}, true)
+	restingOrder(t, store, tradeCode, models.Ask, 101, 2)
+
+	// Market data shows displayed orders only
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 1)
+	assert.True(t, decimal.NewFromInt(101).Equal(marketData.Asks[0].Price))
+	assert.Equal(t, uint(2), marketData.Asks[0].Quantity)
+
+	bestBidOffer, err := store.BestBidOffer(context.Background(), tradeCode)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(101).Equal(bestBidOffer.Ask.Price))
+
+	// Hidden order matches as usual
+	bid := conformanceOrder(t, store, models.NewImmediateOrCancelOrder, models.OrderGeneralInfo{
+		TradeCode:    tradeCode,
+		Price:        decimal.NewFromInt(100),
+		Quantity:     3,
+		Operation:    models.Bid,
+		CounterParty: "bid",
+	}, false)
+
+	trades, err := store.ExecuteOrder(context.Background(), bid)
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, hidden.ID, trades[0].SellOrderID)
+	assert.Equal(t, uint(3), trades[0].Quantity)
+
+	hiddenFromStore, err := store.OrderByID(context.Background(), hidden.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), hiddenFromStore.Quantity)
+	assert.True(t, hiddenFromStore.IsHidden)
+}
+
+func testMarketDataSnapshot(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	_, err := store.MarketDataSnapshot(context.Background(), uuid.Nil, 0)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	marketData, err := store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, marketData.Asks)
+	assert.Empty(t, marketData.Bids)
+
+	restingOrder(t, store, tradeCode, models.Ask, 110, 1)
+	restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+	restingOrder(t, store, tradeCode, models.Ask, 100, 3)
+	restingOrder(t, store, tradeCode, models.Bid, 90, 4)
+	restingOrder(t, store, tradeCode, models.Bid, 95, 5)
+	restingOrder(t, store, uuid.New(), models.Bid, 99, 6)
+
+	// Levels are aggregated and ordered best price first on both sides
+	marketData, err = store.MarketDataSnapshot(context.Background(), tradeCode, 0)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 2)
+	assert.True(t, decimal.NewFromInt(100).Equal(marketData.Asks[0].Price))
+	assert.Equal(t, uint(5), marketData.Asks[0].Quantity)
+	assert.Equal(t, uint(2), marketData.Asks[0].Orders)
+	assert.True(t, decimal.NewFromInt(110).Equal(marketData.Asks[1].Price))
+	assert.Len(t, marketData.Bids, 2)
+	assert.True(t, decimal.NewFromInt(95).Equal(marketData.Bids[0].Price))
+	assert.Equal(t, uint(5), marketData.Bids[0].Quantity)
+	assert.True(t, decimal.NewFromInt(90).Equal(marketData.Bids[1].Price))
+
+	marketData, err = store.MarketDataSnapshot(context.Background(), tradeCode, 1)
+	assert.Nil(t, err)
+	assert.Len(t, marketData.Asks, 1)
+	assert.Equal(t, uint(5), marketData.Asks[0].Quantity)
+	assert.Len(t, marketData.Bids, 1)
+	assert.True(t, decimal.NewFromInt(95).Equal(marketData.Bids[0].Price))
+}
+
+func testBestBidOffer(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+
+	_, err := store.BestBidOffer(context.Background(), uuid.Nil)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	bestBidOffer, err := store.BestBidOffer(context.Background(), tradeCode)
+	assert.Nil(t, err)
+	assert.Equal(t, tradeCode, bestBidOffer.TradeCode)
+	assert.Nil(t, bestBidOffer.Bid)
+	assert.Nil(t, bestBidOffer.Ask)
+	assert.Nil(t, bestBidOffer.Spread)
+
+	restingOrder(t, store, tradeCode, models.Ask, 110, 1)
+	restingOrder(t, store, tradeCode, models.Ask, 100, 2)
+	restingOrder(t, store, tradeCode, models.Bid, 90, 4)
+	restingOrder(t, store, tradeCode, models.Bid, 95, 5)
+
+	bestBidOffer, err = store.BestBidOffer(context.Background(), tradeCode)
+	assert.Nil(t, err)
+	assert.True(t, decimal.NewFromInt(95).Equal(bestBidOffer.Bid.Price))
+	assert.Equal(t, uint(5), bestBidOffer.Bid.Quantity)
+	assert.True(t, decimal.NewFromInt(100).Equal(bestBidOffer.Ask.Price))
+	assert.Equal(t, uint(2), bestBidOffer.Ask.Quantity)
+	assert.True(t, decimal.NewFromInt(5).Equal(*bestBidOffer.Spread))
+	assert.True(t, decimal.RequireFromString("97.5").Equal(*bestBidOffer.MidPrice))
+}
+
+func testRecordTrade(t *testing.T, store datastore.DataStore) {
+	assert.Equal(t, datastore.ErrEmptyStruct, store.RecordTrade(context.Background(), nil))
+	assert.Equal(t, datastore.ErrZeroID, store.RecordTrade(context.Background(), &models.Trade{}))
+
+	trade := conformanceTrade(uuid.New(), "buyer", time.Now().UTC())
+	assert.Nil(t, store.RecordTrade(context.Background(), &trade))
+
+	trades, err := store.Trades(context.Background(), models.TradeFilter{TradeCode: trade.TradeCode})
+	assert.Nil(t, err)
+	assert.Len(t, trades, 1)
+	assertSameTrade(t, trade, trades[0])
+}
+
+func testTrades(t *testing.T, store datastore.DataStore) {
+	tradeCode := uuid.New()
+	counterParty := uuid.New().String()
+	now := time.Now().UTC()
+
+	late := conformanceTrade(tradeCode, counterParty, now)
+	early := conformanceTrade(tradeCode, "buyer", now.Add(-time.Hour))
+	other := conformanceTrade(uuid.New(), counterParty, now.Add(-time.Minute))
+	for _, trade := range []models.Trade{late, early, other} {
+		trade := trade
+		assert.Nil(t, store.RecordTrade(context.Background(), &trade))
+	}
+
+	// Trades come in the order of execution
+	trades, err := store.Trades(context.Background(), models.TradeFilter{TradeCode: tradeCode})
+	assert.Nil(t, err)
+	assert.Len(t, trades, 2)
+	assertSameTrade(t, early, trades[0])
+	assertSameTrade(t, late, trades[1])
+
+	trades, _ = store.Trades(context.Background(), models.TradeFilter{CounterParty: counterParty})
+	assert.Len(t, trades, 2)
+	assertSameTrade(t, other, trades[0])
+	assertSameTrade(t, late, trades[1])
+
+	// Time range includes its start and excludes its end
+	from := now.Add(-time.Minute)
+	trades, _ = store.Trades(context.Background(), models.TradeFilter{CounterParty: counterParty, From: &from, To: &now})
+	assert.Len(t, trades, 1)
+	assertSameTrade(t, other, trades[0])
+}
+
+func conformanceTrade(tradeCode uuid.UUID, counterParty string, executedAt time.Time) models.Trade {
+	return models.Trade{
+		ID:               uuid.New(),
+		BuyOrderID:       uuid.New(),
+		SellOrderID:      uuid.New(),
+		BuyCounterParty:  counterParty,
+		SellCounterParty: "seller",
+		TradeCode:        tradeCode,
+		Price:            decimal.RequireFromString("10.25"),
+		Quantity:         1,
+		ExecutedAt:       executedAt,
+		Aggressor:        models.Bid,
+	}
+}
+
+// assertSameTrade compares trades by value, since stores may change decimal and time representation
+func assertSameTrade(t *testing.T, expected, actual models.Trade) {
+	t.Helper()
+
+	assert.Equal(t, expected.ID, actual.ID)
+	assert.Equal(t, expected.BuyOrderID, actual.BuyOrderID)
+	assert.Equal(t, expected.SellOrderID, actual.SellOrderID)
+	assert.Equal(t, expected.BuyCounterParty, actual.BuyCounterParty)
+	assert.Equal(t, expected.SellCounterParty, actual.SellCounterParty)
+	assert.Equal(t, expected.TradeCode, actual.TradeCode)
+	assert.True(t, expected.Price.Equal(actual.Price))
+	assert.Equal(t, expected.Quantity, actual.Quantity)
+	assert.True(t, expected.ExecutedAt.Equal(actual.ExecutedAt))
+	assert.Equal(t, expected.Aggressor, actual.Aggressor)
+}
+
+func conformanceInstrument() *models.Instrument {
+	return &models.Instrument{
+		TradeCode:   uuid.New(),
+		Symbol:      "TEST",
+		TickSize:    decimal.RequireFromString("0.05"),
+		LotSize:     10,
+		MinQuantity: 10,
+		MaxQuantity: 1000,
+		PriceScale:  2,
+		Currency:    "USD",
+		Status:      models.Active,
+	}
+}
+
+func testInstruments(t *testing.T, store datastore.DataStore) {
+	assert.Equal(t, datastore.ErrEmptyStruct, store.SaveInstrument(context.Background(), nil))
+	assert.Equal(t, datastore.ErrZeroID, store.SaveInstrument(context.Background(), &models.Instrument{}))
+
+	_, err := store.InstrumentByTradeCode(context.Background(), uuid.Nil)
+	assert.Equal(t, datastore.ErrZeroID, err)
+
+	_, err = store.InstrumentByTradeCode(context.Background(), uuid.New())
+	assert.Equal(t, datastore.ErrInstrumentDoesNotExist, err)
+
+	instrument := conformanceInstrument()
+	assert.Nil(t, store.SaveInstrument(context.Background(), instrument))
+
+	instrumentFromStore, err := store.InstrumentByTradeCode(context.Background(), instrument.TradeCode)
+	assert.Nil(t, err)
+	assert.True(t, instrument.TickSize.Equal(instrumentFromStore.TickSize))
+	instrumentFromStore.TickSize = instrument.TickSize
+	assert.Equal(t, instrument, instrumentFromStore)
+
+	// Saving again replaces reference data
+	instrument.Status = models.Halted
+	assert.Nil(t, store.SaveInstrument(context.Background(), instrument))
+
+	instrumentFromStore, err = store.InstrumentByTradeCode(context.Background(), instrument.TradeCode)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Halted, instrumentFromStore.Status)
+}
+
+func testInstrumentOrders(t *testing.T, store datastore.DataStore) {
+	instrument := conformanceInstrument()
+	assert.Nil(t, store.SaveInstrument(context.Background(), instrument))
+
+	newOrder := func(price string, quantity uint) *models.Order {
+		return conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+			TradeCode:    instrument.TradeCode,
+			Price:        decimal.RequireFromString(price),
+			Quantity:     quantity,
+			Operation:    models.Ask,
+			CounterParty: "AskCounterParty",
+		}, false)
+	}
+
+	assert.Equal(t, datastore.ErrPriceScale, store.CreateOrder(context.Background(), newOrder("10.001", 10)))
+	assert.Equal(t, datastore.ErrOffTickPrice, store.CreateOrder(context.Background(), newOrder("10.01", 10)))
+	assert.Equal(t, datastore.ErrOffLotQuantity, store.CreateOrder(context.Background(), newOrder("10.05", 15)))
+	assert.Equal(t, datastore.ErrOrderSize, store.CreateOrder(context.Background(), newOrder("10.05", 1010)))
+
+	_, err := store.ExecuteOrder(context.Background(), newOrder("10.01", 10))
+	assert.Equal(t, datastore.ErrOffTickPrice, err)
+
+	// Peaks of iceberg order are whole lots
+	offLotIceberg := newOrder("10.05", 40)
+	offLotIceberg.DisplayQuantity = 15
+	assert.Equal(t, datastore.ErrOffLotDisplayQuantity, store.CreateOrder(context.Background(), offLotIceberg))
+
+	// Stop price is checked the same way as the price
+	offTickStop := newOrder("10.05", 10)
+	offTickStop.Kind = models.StopLimit
+	offTickStop.StopPrice = decimal.RequireFromString("10.02")
+	_, err = store.ExecuteOrder(context.Background(), offTickStop)
+	assert.Equal(t, datastore.ErrOffTickPrice, err)
+
+	results, err := store.CreateOrders(context.Background(), []*models.Order{newOrder("10.05", 20), newOrder("10.05", 25)})
+	assert.Nil(t, err)
+	assert.Equal(t, []error{nil, datastore.ErrOffLotQuantity}, results)
+
+	ask := newOrder("10.1", 10)
+	assert.Nil(t, store.CreateOrder(context.Background(), ask))
+	assert.Equal(t, datastore.ErrOffTickPrice, store.AmendOrder(context.Background(), ask.ID, decimal.RequireFromString("10.12"), 10))
+	assert.Equal(t, datastore.ErrOffLotQuantity, store.AmendOrder(context.Background(), ask.ID, decimal.RequireFromString("10.15"), 5))
+	assert.Nil(t, store.AmendOrder(context.Background(), ask.ID, decimal.RequireFromString("10.15"), 20))
+
+	// Orders of other instruments are not restricted by the reference data
+	other := conformanceOrder(t, store, models.NewGoodTillCancelledOrder, models.OrderGeneralInfo{
+		TradeCode:    uuid.New(),
+		Price:        decimal.RequireFromString("10.01"),
+		Quantity:     15,
+		Operation:    models.Ask,
+		CounterParty: "AskCounterParty",
+	}, false)
+	assert.Nil(t, store.CreateOrder(context.Background(), other))
+
+	instrument.Status = models.Halted
+	assert.Nil(t, store.SaveInstrument(context.Background(), instrument))
+	assert.Equal(t, datastore.ErrInstrumentNotTradable, store.CreateOrder(context.Background(), newOrder("10.05", 10)))
+}
@@ -6,4 +6,33 @@ var (
 	ErrEmptyStruct       = errors.New("no empty struct")
 	ErrZeroID            = errors.New("no zero id")
 	ErrOrderDoesNotExist = errors.New("order does not exist")
+	ErrZeroQuantity      = errors.New("no zero quantity")
+	ErrNonPositivePrice  = errors.New("no zero or negative price")
+	ErrUnknownOperation  = errors.New("no unknown operation")
+	ErrEmptyCounterParty = errors.New("no empty counterparty")
+	ErrPastValidUntil    = errors.New("no past valid until")
+	ErrRestingMarket     = errors.New("no market order resting in the book")
+	ErrNonPositiveStop   = errors.New("no zero or negative stop price")
+	ErrRestingStop       = errors.New("no stop order resting in the book before it is triggered")
+	ErrNoLastTradePrice  = errors.New("instrument has not been traded yet")
+	ErrPriceScale        = errors.New("price has more decimal places than the instrument allows")
+	ErrPriceOutOfRange   = errors.New("price is out of the storable range")
+	ErrCrossingAmend     = errors.New("no amendment to a price crossing the book")
+	ErrDisplayQuantity   = errors.New("no display quantity above order quantity")
+	ErrHiddenIceberg     = errors.New("no display quantity for hidden order")
+	ErrUnknownPostOnly   = errors.New("no unknown post-only mode")
+	ErrPostOnlyType      = errors.New("no post-only order which can't rest in the book")
+	ErrPostOnlyCross     = errors.New("no post-only order taking liquidity")
+
+	ErrInstrumentDoesNotExist  = errors.New("instrument does not exist")
+	ErrEmptySymbol             = errors.New("no empty symbol")
+	ErrNonPositiveTickSize     = errors.New("no zero or negative tick size")
+	ErrZeroLotSize             = errors.New("no zero lot size")
+	ErrInvalidSizeLimits       = errors.New("no max order size below min order size")
+	ErrUnknownInstrumentStatus = errors.New("no unknown instrument status")
+	ErrInstrumentNotTradable   = errors.New("no orders for instrument which is not active")
+	ErrOffTickPrice            = errors.New("no price off the instrument tick size")
+	ErrOffLotQuantity          = errors.New("no quantity off the instrument lot size")
+	ErrOffLotDisplayQuantity   = errors.New("no display quantity off the instrument lot size")
+	ErrOrderSize               = errors.New("no quantity out of the instrument order size limits")
 )
@@ -0,0 +1,103 @@
+package inmemory
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"sort"
+)
+
+// book keeps orders of a single instrument, it is guarded by OrderBook lock
+type book struct {
+	asks map[uuid.UUID]models.Order
+	bids map[uuid.UUID]models.Order
+	// Trigger book of stop orders waiting for the last trade price to reach them
+	stops map[uuid.UUID]models.Order
+}
+
+func newBook() *book {
+	return &book{
+		asks:  make(map[uuid.UUID]models.Order, 0),
+		bids:  make(map[uuid.UUID]models.Order, 0),
+		stops: make(map[uuid.UUID]models.Order, 0),
+	}
+}
+
+// side returns orders of a given operation
+func (b *book) side(operation models.MarketOperation) map[uuid.UUID]models.Order {
+	if operation == models.Ask {
+		return b.asks
+	}
+
+	return b.bids
+}
+
+// match picks orders acceptable for a given one in price-time priority
+func (b *book) match(order *models.Order) []models.Order {
+	opposite := b.bids
+	if order.Operation == models.Bid {
+		opposite = b.asks
+	}
+
+	matchingOrders := make([]models.Order, 0)
+	for _, resting := range opposite {
+		if resting.IsProcessable() && order.Crosses(resting) && resting.Quantity > 0 {
+			matchingOrders = append(matchingOrders, resting)
+		}
+	}
+
+	sortByPriority(matchingOrders)
+	return matchingOrders
+}
+
+// nextTriggered picks the earliest stop order reached by the last trade price
+func (b *book) nextTriggered(lastPrice decimal.Decimal) (models.Order, bool) {
+	var (
+		next  models.Order
+		found bool
+	)
+
+	for _, stop := range b.stops {
+		if !stop.IsProcessable() || !stop.IsTriggeredBy(lastPrice) {
+			continue
+		}
+
+		if !found || stop.Sequence < next.Sequence {
+			next, found = stop, true
+		}
+	}
+
+	return next, found
+}
+
+// sortByPriority puts orders of the same side in price-time priority
+func sortByPriority(orders []models.Order) {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].HasPriorityOver(orders[j]) })
+}
+
+// bestLevel aggregates the best displayed price level of the side without sorting it, nil for empty side
+func (b *book) bestLevel(operation models.MarketOperation) *models.PriceLevel {
+	var best *models.PriceLevel
+
+	for _, order := range b.side(operation) {
+		if !order.IsProcessable() || order.Quantity == 0 || order.IsHidden {
+			continue
+		}
+
+		snapshot := order.Snapshot()
+		isBetter := best == nil ||
+			(operation == models.Bid && snapshot.Price.GreaterThan(best.Price)) ||
+			(operation == models.Ask && snapshot.Price.LessThan(best.Price))
+
+		if isBetter {
+			best = &models.PriceLevel{Price: snapshot.Price}
+		}
+
+		if best.Price.Equal(snapshot.Price) {
+			best.Quantity += snapshot.Quantity
+			best.Orders++
+		}
+	}
+
+	return best
+}
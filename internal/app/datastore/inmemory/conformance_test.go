@@ -0,0 +1,13 @@
+package inmemory
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore"
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/datastore/datastoretest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	datastoretest.RunConformanceTests(t, func(t *testing.T) (datastore.DataStore, func()) {
+		return New(), func() {}
+	})
+}
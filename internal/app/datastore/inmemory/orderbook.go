@@ -8,42 +8,181 @@ import (
 	"github.com/shopspring/decimal"
 	"sort"
 	"sync"
+	"time"
 )
 
 // Thread safe "in memory" store
 type OrderBook struct {
-	mu   sync.Mutex
-	asks map[uuid.UUID]models.Order
-	bids map[uuid.UUID]models.Order
+	mu sync.Mutex
+	// Order books partitioned by instrument TradeCode
+	books map[uuid.UUID]*book
+	// Instrument TradeCode of every known order by order ID
+	tradeCodes map[uuid.UUID]uuid.UUID
+	sequence   uint64
+	// Trade tape in execution order
+	trades []models.Trade
+	// Reference data by instrument TradeCode
+	instruments map[uuid.UUID]models.Instrument
+	// Latest execution by instrument TradeCode, its price triggers stop orders
+	lastTrades map[uuid.UUID]models.Trade
 }
 
 func New() datastore.DataStore {
 	return &OrderBook{
-		asks: make(map[uuid.UUID]models.Order, 0),
-		bids: make(map[uuid.UUID]models.Order, 0),
+		books:       make(map[uuid.UUID]*book, 0),
+		tradeCodes:  make(map[uuid.UUID]uuid.UUID, 0),
+		trades:      make([]models.Trade, 0),
+		instruments: make(map[uuid.UUID]models.Instrument, 0),
+		lastTrades:  make(map[uuid.UUID]models.Trade, 0),
 	}
 }
 
-// CreateOrder validates order on a very basic level and saves it
+// CreateOrder validates order and saves it
 func (o *OrderBook) CreateOrder(ctx context.Context, order *models.Order) error {
-	if order == nil {
-		return datastore.ErrEmptyStruct
+	if err := datastore.ValidateRestingOrder(order); err != nil {
+		return err
 	}
 
-	if order.ID == uuid.Nil {
-		return datastore.ErrZeroID
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.checkInstrument(order); err != nil {
+		return err
+	}
+
+	if err := o.postOnly(order); err != nil {
+		return err
+	}
+
+	o.save(order)
+	return nil
+}
+
+// CreateOrders validates every order of the batch and saves the valid ones under a single lock
+func (o *OrderBook) CreateOrders(ctx context.Context, orders []*models.Order) ([]error, error) {
+	results := make([]error, len(orders))
+	for i, order := range orders {
+		results[i] = datastore.ValidateRestingOrder(order)
 	}
 
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	if order.Operation == models.Ask {
-		o.asks[order.ID] = *order
+	for i, order := range orders {
+		if results[i] != nil {
+			continue
+		}
+
+		if results[i] = o.checkInstrument(order); results[i] != nil {
+			continue
+		}
+
+		if results[i] = o.postOnly(order); results[i] == nil {
+			o.save(order)
+		}
+	}
+
+	return results, nil
+}
+
+// checkInstrument checks order prices and quantity against reference data of its instrument, caller must hold the lock
+func (o *OrderBook) checkInstrument(order *models.Order) error {
+	instrument, ok := o.instruments[order.TradeCode]
+	if !ok {
 		return nil
 	}
 
-	o.bids[order.ID] = *order
-	return nil
+	return datastore.ValidateOrderForInstrument(&instrument, order)
+}
+
+// postOnly rejects or reprices post-only order which would take liquidity of the instrument book,
+// caller must hold the lock
+func (o *OrderBook) postOnly(order *models.Order) error {
+	if !order.IsPostOnly() {
+		return nil
+	}
+
+	var best *models.Order
+	if crossed := o.book(order.TradeCode).match(order); len(crossed) > 0 {
+		best = &crossed[0]
+	}
+
+	var instrument *models.Instrument
+	if reference, ok := o.instruments[order.TradeCode]; ok {
+		instrument = &reference
+	}
+
+	return datastore.ApplyPostOnly(order, best, instrument)
+}
+
+// book returns order book of the instrument or an empty one, caller must hold the lock
+func (o *OrderBook) book(tradeCode uuid.UUID) *book {
+	if instrumentBook, ok := o.books[tradeCode]; ok {
+		return instrumentBook
+	}
+
+	return newBook()
+}
+
+// find looks the order up in its instrument book, caller must hold the lock
+func (o *OrderBook) find(id uuid.UUID) (models.Order, bool) {
+	tradeCode, ok := o.tradeCodes[id]
+	if !ok {
+		return models.Order{}, false
+	}
+
+	instrumentBook := o.books[tradeCode]
+	if order, orderInAsks := instrumentBook.asks[id]; orderInAsks {
+		return order, true
+	}
+
+	if order, orderInBids := instrumentBook.bids[id]; orderInBids {
+		return order, true
+	}
+
+	order, orderInStops := instrumentBook.stops[id]
+	return order, orderInStops
+}
+
+// clone copies general info of the order, so the book never shares it with callers
+// and executions don't change orders callers read without the lock
+func clone(order models.Order) models.Order {
+	info := *order.OrderGeneralInfo
+	if info.ValidUntil != nil {
+		validUntil := *info.ValidUntil
+		info.ValidUntil = &validUntil
+	}
+
+	order.OrderGeneralInfo = &info
+	return order
+}
+
+// save stamps order arrival, displays the first peak of iceberg order and puts it to its side
+// of the instrument book or to the trigger book if it is a stop order, caller must hold the lock
+func (o *OrderBook) save(order *models.Order) {
+	order.Sequence = o.nextSequence()
+	order.CreatedAt = time.Now().UTC()
+	order.Open()
+	order.Replenish()
+
+	instrumentBook, ok := o.books[order.TradeCode]
+	if !ok {
+		instrumentBook = newBook()
+		o.books[order.TradeCode] = instrumentBook
+	}
+
+	if order.IsStop() {
+		instrumentBook.stops[order.ID] = clone(*order)
+	} else {
+		instrumentBook.side(order.Operation)[order.ID] = clone(*order)
+	}
+	o.tradeCodes[order.ID] = order.TradeCode
+}
+
+// nextSequence gives arrival sequence number, caller must hold the lock
+func (o *OrderBook) nextSequence() uint64 {
+	o.sequence++
+	return o.sequence
 }
 
 // DisableOrder to remove it from market snapshot and other reads
@@ -55,21 +194,76 @@ func (o *OrderBook) DisableOrder(ctx context.Context, id uuid.UUID) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	if order, orderInAsks := o.asks[id]; orderInAsks {
-		if order.IsProcessable() {
-			o.asks[id].OrderGeneralInfo.IsEnabled = false
-		}
-		return nil
+	order, ok := o.find(id)
+	if !ok {
+		return datastore.ErrOrderDoesNotExist
+	}
+
+	// Filled orders stay filled
+	if order.IsProcessable() && order.Status != models.Filled {
+		order.Cancel()
+	}
+
+	return nil
+}
+
+// AmendOrder changes price and quantity left to fill of the order keeping its ID,
+// time priority is kept on quantity reduction and lost on any other change.
+// Price crossing the book is rejected, post-only order follows its post-only mode
+func (o *OrderBook) AmendOrder(ctx context.Context, id uuid.UUID, price decimal.Decimal, quantity uint) error {
+	if id == uuid.Nil {
+		return datastore.ErrZeroID
+	}
+
+	if !price.IsPositive() {
+		return datastore.ErrNonPositivePrice
+	}
+
+	if quantity == 0 {
+		return datastore.ErrZeroQuantity
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// Filled orders can't be revived by amendment
+	order, ok := o.find(id)
+	if !ok || !order.IsProcessable() || order.Quantity == 0 {
+		return datastore.ErrOrderDoesNotExist
+	}
+
+	amended := clone(order)
+	amended.Price = price
+	amended.Quantity = quantity
+	if err := o.checkInstrument(&amended); err != nil {
+		return err
 	}
 
-	if order, orderInBids := o.bids[id]; orderInBids {
-		if order.IsProcessable() {
-			o.bids[id].OrderGeneralInfo.IsEnabled = false
+	// Amendment never executes, so the order can't be moved to a price it would cross at,
+	// post-only order is rejected or repriced by its mode instead
+	if !price.Equal(order.Price) {
+		if err := o.postOnly(&amended); err != nil {
+			return err
 		}
-		return nil
+
+		if crossed := o.book(order.TradeCode).match(&amended); len(crossed) > 0 {
+			return datastore.ErrCrossingAmend
+		}
+		price = amended.Price
 	}
 
-	return datastore.ErrOrderDoesNotExist
+	keepsPriority := order.KeepsPriorityOn(price, quantity)
+	if !keepsPriority {
+		order.Sequence = o.nextSequence()
+	}
+
+	order.Price = price
+	order.Quantity = quantity
+	order.OriginalQuantity = order.FilledQuantity + quantity
+	if !keepsPriority || order.PeakQuantity > quantity {
+		order.Replenish()
+	}
+	return nil
 }
 
 // OrderByID returns only enabled and not expired order
@@ -81,88 +275,309 @@ func (o *OrderBook) OrderByID(ctx context.Context, id uuid.UUID) (*models.Order,
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	if order, orderInAsks := o.asks[id]; orderInAsks {
-		if order.IsProcessable() {
-			return &order, nil
-		}
+	if order, ok := o.find(id); ok && order.IsProcessable() {
+		order = clone(order)
+		return &order, nil
 	}
 
-	if order, orderInBids := o.bids[id]; orderInBids {
-		if order.IsProcessable() {
-			return &order, nil
-		}
+	return nil, datastore.ErrOrderDoesNotExist
+}
+
+// FindOrder returns the order whatever its status is, with expired status for open order past its validity
+func (o *OrderBook) FindOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	if id == uuid.Nil {
+		return nil, datastore.ErrZeroID
 	}
 
-	return nil, datastore.ErrOrderDoesNotExist
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	order, ok := o.find(id)
+	if !ok {
+		return nil, datastore.ErrOrderDoesNotExist
+	}
+
+	order = clone(order)
+	order.Status = order.ActualStatus()
+	return &order, nil
 }
 
-// MatchOrder to get available bids/asks for a given order
+// MatchOrder to get available bids/asks of the same instrument for a given order in price-time priority
 func (o *OrderBook) MatchOrder(ctx context.Context, order *models.Order) ([]models.Order, error) {
-	if order == nil {
-		return nil, datastore.ErrEmptyStruct
+	if err := datastore.ValidateMatchingOrder(order); err != nil {
+		return nil, err
 	}
 
-	if order.Operation == models.Bid {
-		return o.matchBid(order.Price)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	matchingOrders := o.book(order.TradeCode).match(order)
+	for i := range matchingOrders {
+		matchingOrders[i] = clone(matchingOrders[i])
 	}
 
-	return o.matchAsk(order.Price)
+	return matchingOrders, nil
 }
 
-func (o *OrderBook) matchBid(bidPrice decimal.Decimal) ([]models.Order, error) {
+// ExecuteOrder fills order against the opposite side of its instrument in price-time priority,
+// decrements quantities of both sides and rests the remainder in the book.
+// Remainder of immediate-or-cancel order is cancelled, fill-or-kill order
+// which can't be filled completely leaves the book untouched.
+// Stop order waits in the trigger book until the last trade price reaches it
+func (o *OrderBook) ExecuteOrder(ctx context.Context, order *models.Order) ([]models.Trade, error) {
+	if err := datastore.ValidateOrder(order); err != nil {
+		return nil, err
+	}
+
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	matchingAsks := make([]models.Order, 0)
+	if err := o.checkInstrument(order); err != nil {
+		return nil, err
+	}
+
+	if err := o.postOnly(order); err != nil {
+		return nil, err
+	}
 
-	for _, ask := range o.asks {
-		if ask.IsProcessable() && ask.Price.LessThanOrEqual(bidPrice) && ask.Quantity > 0 {
-			matchingAsks = append(matchingAsks, ask)
+	if order.IsStop() {
+		lastTrade, ok := o.lastTrades[order.TradeCode]
+		if !ok || !order.IsTriggeredBy(lastTrade.Price) {
+			o.save(order)
+			return make([]models.Trade, 0), nil
 		}
+
+		order.Trigger()
 	}
 
-	return matchingAsks, nil
+	trades := o.execute(order)
+	return append(trades, o.triggerStops(order.TradeCode)...), nil
 }
 
-func (o *OrderBook) matchAsk(askPrice decimal.Decimal) ([]models.Order, error) {
+// execute matches the order and rests or cancels its remainder, caller must hold the lock
+func (o *OrderBook) execute(order *models.Order) []models.Trade {
+	trades := make([]models.Trade, 0)
+	candidates := o.book(order.TradeCode).match(order)
+	if order.Type == models.FillOrKill && models.TotalQuantity(candidates) < order.Quantity {
+		order.Cancel()
+		return trades
+	}
+
+	for i := 0; i < len(candidates) && order.Quantity > 0; i++ {
+		resting := candidates[i]
+		quantity := resting.VisibleQuantity()
+		if order.Quantity < quantity {
+			quantity = order.Quantity
+		}
+
+		// General info is shared by pointer, so the stored order is updated too
+		resting.Fill(quantity, resting.Price)
+		order.Fill(quantity, resting.Price)
+
+		trade := models.NewTrade(*order, resting, quantity)
+		o.record(*trade)
+		trades = append(trades, *trade)
+
+		// Iceberg order with a filled peak goes behind its price level with a new one
+		if resting.NeedsReplenishment() {
+			resting.Replenish()
+			resting.Sequence = o.nextSequence()
+			candidates = models.Requeue(candidates, i, resting)
+		}
+	}
+
+	if order.Quantity > 0 {
+		if !order.RestsInBook() {
+			order.Cancel()
+			return trades
+		}
+
+		o.save(order)
+	}
+
+	return trades
+}
+
+// triggerStops executes stop orders of the instrument reached by the last trade price
+// one by one in arrival order, since every execution may move the price further, caller must hold the lock
+func (o *OrderBook) triggerStops(tradeCode uuid.UUID) []models.Trade {
+	trades := make([]models.Trade, 0)
+	for {
+		lastTrade, ok := o.lastTrades[tradeCode]
+		if !ok {
+			return trades
+		}
+
+		instrumentBook := o.book(tradeCode)
+		stop, ok := instrumentBook.nextTriggered(lastTrade.Price)
+		if !ok {
+			return trades
+		}
+
+		delete(instrumentBook.stops, stop.ID)
+		stop.Trigger()
+		trades = append(trades, o.execute(&stop)...)
+
+		// Stop which doesn't rest after execution is kept along with the resting orders as well,
+		// so it can be found whatever its status is
+		if _, ok := instrumentBook.side(stop.Operation)[stop.ID]; !ok {
+			instrumentBook.side(stop.Operation)[stop.ID] = stop
+		}
+	}
+}
+
+// record puts the trade to the tape and tracks the latest execution of its instrument, caller must hold the lock
+func (o *OrderBook) record(trade models.Trade) {
+	o.trades = append(o.trades, trade)
+
+	if lastTrade, ok := o.lastTrades[trade.TradeCode]; !ok || !trade.ExecutedAt.Before(lastTrade.ExecutedAt) {
+		o.lastTrades[trade.TradeCode] = trade
+	}
+}
+
+// RecordTrade appends trade to the trade tape and triggers stop orders reached by the last trade price
+func (o *OrderBook) RecordTrade(ctx context.Context, trade *models.Trade) error {
+	if trade == nil {
+		return datastore.ErrEmptyStruct
+	}
+
+	if trade.ID == uuid.Nil {
+		return datastore.ErrZeroID
+	}
+
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	matchingBids := make([]models.Order, 0)
+	o.record(*trade)
+	o.triggerStops(trade.TradeCode)
+	return nil
+}
+
+// LastTradePrice is the price of the latest execution of the instrument
+func (o *OrderBook) LastTradePrice(ctx context.Context, tradeCode uuid.UUID) (decimal.Decimal, error) {
+	if tradeCode == uuid.Nil {
+		return decimal.Zero, datastore.ErrZeroID
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-	for _, bid := range o.bids {
-		if bid.IsProcessable() && bid.Price.GreaterThanOrEqual(askPrice) && bid.Quantity > 0 {
-			matchingBids = append(matchingBids, bid)
+	lastTrade, ok := o.lastTrades[tradeCode]
+	if !ok {
+		return decimal.Zero, datastore.ErrNoLastTradePrice
+	}
+
+	return lastTrade.Price, nil
+}
+
+// Trades returns trade history passing the filter ordered by execution time
+func (o *OrderBook) Trades(ctx context.Context, filter models.TradeFilter) ([]models.Trade, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	trades := make([]models.Trade, 0)
+	for _, trade := range o.trades {
+		if filter.Matches(trade) {
+			trades = append(trades, trade)
 		}
 	}
 
-	return matchingBids, nil
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].ExecutedAt.Before(trades[j].ExecutedAt) })
+	return trades, nil
 }
 
-// MarketDataSnapshot to get actual market data ordered by price
-func (o *OrderBook) MarketDataSnapshot(ctx context.Context) (*models.MarketDataSnapshot, error) {
+// MarketDataSnapshot to get actual price levels of the instrument ordered best price first,
+// depth limits number of the best levels of each side, zero depth means no limit.
+// Hidden orders are left out
+func (o *OrderBook) MarketDataSnapshot(
+	ctx context.Context,
+	tradeCode uuid.UUID,
+	depth uint,
+) (*models.MarketDataSnapshot, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	instrumentBook := o.book(tradeCode)
+
 	asks := make([]models.OrderSnapshot, 0)
-	for _, ask := range o.asks {
-		if ask.IsProcessable() {
+	for _, ask := range instrumentBook.asks {
+		if ask.IsProcessable() && ask.Quantity > 0 && !ask.IsHidden {
 			asks = append(asks, *ask.Snapshot())
 		}
 	}
 
 	bids := make([]models.OrderSnapshot, 0)
-	for _, bid := range o.bids {
-		if bid.IsProcessable() {
+	for _, bid := range instrumentBook.bids {
+		if bid.IsProcessable() && bid.Quantity > 0 && !bid.IsHidden {
 			bids = append(bids, *bid.Snapshot())
 		}
 	}
 
 	sort.SliceStable(asks, func(i, j int) bool { return asks[i].Price.LessThan(asks[j].Price) })
-	sort.SliceStable(bids, func(i, j int) bool { return bids[i].Price.LessThan(bids[j].Price) })
+	sort.SliceStable(bids, func(i, j int) bool { return bids[i].Price.GreaterThan(bids[j].Price) })
+
+	askLevels := models.NewPriceLevels(asks)
+	bidLevels := models.NewPriceLevels(bids)
+	if depth > 0 && uint(len(askLevels)) > depth {
+		askLevels = askLevels[:depth]
+	}
+	if depth > 0 && uint(len(bidLevels)) > depth {
+		bidLevels = bidLevels[:depth]
+	}
 
 	return &models.MarketDataSnapshot{
-		Asks: asks,
-		Bids: bids,
+		Asks: askLevels,
+		Bids: bidLevels,
 	}, nil
 }
+
+// BestBidOffer to get top of the instrument book without building a full snapshot
+func (o *OrderBook) BestBidOffer(ctx context.Context, tradeCode uuid.UUID) (*models.BestBidOffer, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	instrumentBook := o.book(tradeCode)
+	return models.NewBestBidOffer(
+		tradeCode,
+		instrumentBook.bestLevel(models.Bid),
+		instrumentBook.bestLevel(models.Ask),
+	), nil
+}
+
+// SaveInstrument creates or replaces reference data of the instrument
+func (o *OrderBook) SaveInstrument(ctx context.Context, instrument *models.Instrument) error {
+	if err := datastore.ValidateInstrument(instrument); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.instruments[instrument.TradeCode] = *instrument
+	return nil
+}
+
+// InstrumentByTradeCode returns reference data of the instrument
+func (o *OrderBook) InstrumentByTradeCode(ctx context.Context, tradeCode uuid.UUID) (*models.Instrument, error) {
+	if tradeCode == uuid.Nil {
+		return nil, datastore.ErrZeroID
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	instrument, ok := o.instruments[tradeCode]
+	if !ok {
+		return nil, datastore.ErrInstrumentDoesNotExist
+	}
+
+	return &instrument, nil
+}
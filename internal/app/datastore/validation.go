@@ -0,0 +1,213 @@
+package datastore
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// ValidateOrder checks the order may be stored and gives the reason of its rejection otherwise,
+// every store validates orders with it before saving them
+func ValidateOrder(order *models.Order) error {
+	if order == nil || order.OrderGeneralInfo == nil {
+		return ErrEmptyStruct
+	}
+
+	if order.ID == uuid.Nil {
+		return ErrZeroID
+	}
+
+	// Market orders may go without a price limit
+	if order.Price.IsNegative() || (order.HasPriceLimit() && !order.Price.IsPositive()) {
+		return ErrNonPositivePrice
+	}
+
+	if order.IsStop() && !order.StopPrice.IsPositive() {
+		return ErrNonPositiveStop
+	}
+
+	if order.Quantity == 0 {
+		return ErrZeroQuantity
+	}
+
+	// Iceberg orders display a part of their quantity only
+	if order.DisplayQuantity > order.Quantity {
+		return ErrDisplayQuantity
+	}
+
+	if order.IsHidden && order.IsIceberg() {
+		return ErrHiddenIceberg
+	}
+
+	if !order.Operation.IsKnown() {
+		return ErrUnknownOperation
+	}
+
+	if order.CounterParty == "" {
+		return ErrEmptyCounterParty
+	}
+
+	if order.IsPostOnly() && !order.PostOnly.IsKnown() {
+		return ErrUnknownPostOnly
+	}
+
+	// Stop orders are excluded as well, since they rest in the trigger book only
+	if order.IsPostOnly() && (!order.RestsInBook() || order.IsStop()) {
+		return ErrPostOnlyType
+	}
+
+	// Orders without validity are never processable, the same as expired ones
+	if order.ValidUntil == nil || !time.Now().UTC().Before(*order.ValidUntil) {
+		return ErrPastValidUntil
+	}
+
+	return nil
+}
+
+// ValidateMatchingOrder checks the order may be matched against the book,
+// it needs neither ID nor quantity since nothing is stored
+func ValidateMatchingOrder(order *models.Order) error {
+	if order == nil || order.OrderGeneralInfo == nil {
+		return ErrEmptyStruct
+	}
+
+	if !order.Operation.IsKnown() {
+		return ErrUnknownOperation
+	}
+
+	return nil
+}
+
+// ValidateRestingOrder checks the order may be put to the book as is
+func ValidateRestingOrder(order *models.Order) error {
+	if err := ValidateOrder(order); err != nil {
+		return err
+	}
+
+	if order.IsMarket() {
+		return ErrRestingMarket
+	}
+
+	if order.IsStop() {
+		return ErrRestingStop
+	}
+
+	return nil
+}
+
+// ApplyPostOnly keeps post-only order from taking liquidity of the best opposite order it crosses,
+// nil best order means it crosses nothing. Repricing needs the instrument tick size,
+// so post-only orders of instruments without reference data are rejected instead
+func ApplyPostOnly(order *models.Order, best *models.Order, instrument *models.Instrument) error {
+	if !order.IsPostOnly() || best == nil {
+		return nil
+	}
+
+	if order.PostOnly != models.PostOnlyReprice || instrument == nil {
+		return ErrPostOnlyCross
+	}
+
+	price := best.Price.Add(instrument.TickSize)
+	if order.Operation == models.Bid {
+		price = best.Price.Sub(instrument.TickSize)
+	}
+
+	if !price.IsPositive() {
+		return ErrPostOnlyCross
+	}
+
+	order.Price = price
+	return nil
+}
+
+// ValidateInstrument checks the instrument reference data may be stored
+func ValidateInstrument(instrument *models.Instrument) error {
+	if instrument == nil {
+		return ErrEmptyStruct
+	}
+
+	if instrument.TradeCode == uuid.Nil {
+		return ErrZeroID
+	}
+
+	if instrument.Symbol == "" {
+		return ErrEmptySymbol
+	}
+
+	if !instrument.TickSize.IsPositive() {
+		return ErrNonPositiveTickSize
+	}
+
+	if instrument.PriceScale < 0 || !fitsScale(instrument.TickSize, instrument.PriceScale) {
+		return ErrPriceScale
+	}
+
+	if instrument.LotSize == 0 {
+		return ErrZeroLotSize
+	}
+
+	if instrument.MaxQuantity != 0 && instrument.MaxQuantity < instrument.MinQuantity {
+		return ErrInvalidSizeLimits
+	}
+
+	if !instrument.Status.IsKnown() {
+		return ErrUnknownInstrumentStatus
+	}
+
+	return nil
+}
+
+// ValidateOrderForInstrument checks the order prices and quantity fit the instrument,
+// orders of instruments without reference data are not restricted
+func ValidateOrderForInstrument(instrument *models.Instrument, order *models.Order) error {
+	if instrument == nil {
+		return nil
+	}
+
+	if !instrument.IsTradable() {
+		return ErrInstrumentNotTradable
+	}
+
+	if err := validatePriceForInstrument(instrument, order.Price); err != nil {
+		return err
+	}
+
+	// Stop price is compared with trade prices, so it must be a tradable price too
+	if order.IsStop() {
+		if err := validatePriceForInstrument(instrument, order.StopPrice); err != nil {
+			return err
+		}
+	}
+
+	if !instrument.IsOnLot(order.Quantity) {
+		return ErrOffLotQuantity
+	}
+
+	// Iceberg peaks are displayed and refilled in whole lots as well
+	if order.IsIceberg() && !instrument.IsOnLot(order.DisplayQuantity) {
+		return ErrOffLotDisplayQuantity
+	}
+
+	if !instrument.FitsSize(order.Quantity) {
+		return ErrOrderSize
+	}
+
+	return nil
+}
+
+func validatePriceForInstrument(instrument *models.Instrument, price decimal.Decimal) error {
+	if !fitsScale(price, instrument.PriceScale) {
+		return ErrPriceScale
+	}
+
+	if !instrument.IsOnTick(price) {
+		return ErrOffTickPrice
+	}
+
+	return nil
+}
+
+func fitsScale(value decimal.Decimal, scale int32) bool {
+	return value.Equal(value.Truncate(scale))
+}
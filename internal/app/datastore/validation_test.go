@@ -0,0 +1,233 @@
+package datastore
+
+import (
+	"github.com/KubaiDoLove/scalable-solutions/internal/app/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestValidateOrder(t *testing.T) {
+	validOrder := func() *models.Order {
+		order, _ := models.NewGoodTillCancelledOrder(&models.OrderGeneralInfo{
+			TradeCode:    uuid.New(),
+			Price:        decimal.NewFromInt(32),
+			Quantity:     5,
+			Operation:    models.Bid,
+			CounterParty: "BidCounterParty",
+		})
+		return order
+	}
+
+	assert.Nil(t, ValidateOrder(validOrder()))
+	assert.Equal(t, ErrEmptyStruct, ValidateOrder(nil))
+	assert.Equal(t, ErrEmptyStruct, ValidateOrder(&models.Order{}))
+
+	past := time.Now().UTC().Add(-time.Second)
+	testCases := []struct {
+		change      func(order *models.Order)
+		expectedErr error
+	}{
+		{func(order *models.Order) { order.ID = uuid.Nil }, ErrZeroID},
+		{func(order *models.Order) { order.Price = decimal.Zero }, ErrNonPositivePrice},
+		{func(order *models.Order) { order.Price = decimal.NewFromInt(-1) }, ErrNonPositivePrice},
+		{func(order *models.Order) { order.Quantity = 0 }, ErrZeroQuantity},
+		{func(order *models.Order) { order.DisplayQuantity = 6 }, ErrDisplayQuantity},
+		{func(order *models.Order) { order.DisplayQuantity, order.IsHidden = 2, true }, ErrHiddenIceberg},
+		{func(order *models.Order) { order.Operation = "" }, ErrUnknownOperation},
+		{func(order *models.Order) { order.Operation = "sell" }, ErrUnknownOperation},
+		{func(order *models.Order) { order.CounterParty = "" }, ErrEmptyCounterParty},
+		{func(order *models.Order) { order.PostOnly = "cancel" }, ErrUnknownPostOnly},
+		{func(order *models.Order) { order.ValidUntil = &past }, ErrPastValidUntil},
+		{func(order *models.Order) { order.ValidUntil = nil }, ErrPastValidUntil},
+	}
+
+	for _, testCase := range testCases {
+		order := validOrder()
+		testCase.change(order)
+		assert.Equal(t, testCase.expectedErr, ValidateOrder(order))
+	}
+
+	iceberg := validOrder()
+	iceberg.DisplayQuantity = 5
+	assert.Nil(t, ValidateOrder(iceberg))
+
+	// Market orders go without a price limit unless protected
+	market := validOrder()
+	market.Kind = models.Market
+	market.Price = decimal.Zero
+	assert.Nil(t, ValidateOrder(market))
+
+	market.Price = decimal.NewFromInt(-1)
+	assert.Equal(t, ErrNonPositivePrice, ValidateOrder(market))
+
+	stop := validOrder()
+	stop.Kind = models.StopMarket
+	assert.Equal(t, ErrNonPositiveStop, ValidateOrder(stop))
+
+	stop.StopPrice = decimal.NewFromInt(30)
+	assert.Nil(t, ValidateOrder(stop))
+
+	// Post-only orders must be able to rest in the book
+	postOnly := validOrder()
+	postOnly.PostOnly = models.PostOnlyReject
+	assert.Nil(t, ValidateOrder(postOnly))
+
+	postOnly.Type = models.ImmediateOrCancel
+	assert.Equal(t, ErrPostOnlyType, ValidateOrder(postOnly))
+
+	postOnly.Type = models.GoodTillCancelled
+	postOnly.Kind = models.Market
+	assert.Equal(t, ErrPostOnlyType, ValidateOrder(postOnly))
+
+	stop.PostOnly = models.PostOnlyReprice
+	assert.Equal(t, ErrPostOnlyType, ValidateOrder(stop))
+}
+
+func TestValidateMatchingOrder(t *testing.T) {
+	assert.Equal(t, ErrEmptyStruct, ValidateMatchingOrder(nil))
+	assert.Equal(t, ErrEmptyStruct, ValidateMatchingOrder(&models.Order{}))
+	assert.Equal(t, ErrUnknownOperation, ValidateMatchingOrder(&models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{Operation: "sell"},
+	}))
+	assert.Nil(t, ValidateMatchingOrder(&models.Order{
+		OrderGeneralInfo: &models.OrderGeneralInfo{Operation: models.Bid},
+	}))
+}
+
+func TestValidateRestingOrder(t *testing.T) {
+	order, _ := models.NewGoodTillCancelledOrder(&models.OrderGeneralInfo{
+		TradeCode:    uuid.New(),
+		Price:        decimal.NewFromInt(32),
+		Quantity:     5,
+		Operation:    models.Bid,
+		CounterParty: "BidCounterParty",
+	})
+	assert.Nil(t, ValidateRestingOrder(order))
+
+	order.Kind = models.Market
+	assert.Equal(t, ErrRestingMarket, ValidateRestingOrder(order))
+
+	order.Kind = models.StopLimit
+	order.StopPrice = decimal.NewFromInt(30)
+	assert.Equal(t, ErrRestingStop, ValidateRestingOrder(order))
+
+	order.Quantity = 0
+	assert.Equal(t, ErrZeroQuantity, ValidateRestingOrder(order))
+}
+
+func TestApplyPostOnly(t *testing.T) {
+	newOrder := func(operation models.MarketOperation, price string, mode models.PostOnlyMode) *models.Order {
+		return &models.Order{OrderGeneralInfo: &models.OrderGeneralInfo{
+			Price:     decimal.RequireFromString(price),
+			Operation: operation,
+			PostOnly:  mode,
+		}}
+	}
+	instrument := testInstrument()
+	bestAsk := newOrder(models.Ask, "10", "")
+
+	// Orders which cross nothing and ordinary orders are left as is
+	bid := newOrder(models.Bid, "10.1", "")
+	assert.Nil(t, ApplyPostOnly(bid, bestAsk, instrument))
+	assert.True(t, decimal.RequireFromString("10.1").Equal(bid.Price))
+
+	bid = newOrder(models.Bid, "10.1", models.PostOnlyReprice)
+	assert.Nil(t, ApplyPostOnly(bid, nil, instrument))
+	assert.True(t, decimal.RequireFromString("10.1").Equal(bid.Price))
+
+	assert.Equal(t, ErrPostOnlyCross, ApplyPostOnly(newOrder(models.Bid, "10", models.PostOnlyReject), bestAsk, instrument))
+	assert.Equal(t, ErrPostOnlyCross, ApplyPostOnly(bid, bestAsk, nil))
+
+	assert.Nil(t, ApplyPostOnly(bid, bestAsk, instrument))
+	assert.True(t, decimal.RequireFromString("9.95").Equal(bid.Price))
+
+	ask := newOrder(models.Ask, "9", models.PostOnlyReprice)
+	assert.Nil(t, ApplyPostOnly(ask, newOrder(models.Bid, "9.5", ""), instrument))
+	assert.True(t, decimal.RequireFromString("9.55").Equal(ask.Price))
+
+	// Bid can't be repriced below the first tick
+	bid = newOrder(models.Bid, "1", models.PostOnlyReprice)
+	assert.Equal(t, ErrPostOnlyCross, ApplyPostOnly(bid, newOrder(models.Ask, "0.05", ""), instrument))
+}
+
+func testInstrument() *models.Instrument {
+	return &models.Instrument{
+		TradeCode:   uuid.New(),
+		Symbol:      "TEST",
+		TickSize:    decimal.RequireFromString("0.05"),
+		LotSize:     10,
+		MinQuantity: 10,
+		MaxQuantity: 1000,
+		PriceScale:  2,
+		Currency:    "USD",
+		Status:      models.Active,
+	}
+}
+
+func TestValidateInstrument(t *testing.T) {
+	assert.Nil(t, ValidateInstrument(testInstrument()))
+	assert.Equal(t, ErrEmptyStruct, ValidateInstrument(nil))
+
+	testCases := []struct {
+		change      func(instrument *models.Instrument)
+		expectedErr error
+	}{
+		{func(instrument *models.Instrument) { instrument.TradeCode = uuid.Nil }, ErrZeroID},
+		{func(instrument *models.Instrument) { instrument.Symbol = "" }, ErrEmptySymbol},
+		{func(instrument *models.Instrument) { instrument.TickSize = decimal.Zero }, ErrNonPositiveTickSize},
+		{func(instrument *models.Instrument) { instrument.PriceScale = 1 }, ErrPriceScale},
+		{func(instrument *models.Instrument) { instrument.PriceScale = -1 }, ErrPriceScale},
+		{func(instrument *models.Instrument) { instrument.LotSize = 0 }, ErrZeroLotSize},
+		{func(instrument *models.Instrument) { instrument.MaxQuantity = 5 }, ErrInvalidSizeLimits},
+		{func(instrument *models.Instrument) { instrument.Status = "" }, ErrUnknownInstrumentStatus},
+	}
+
+	for _, testCase := range testCases {
+		instrument := testInstrument()
+		testCase.change(instrument)
+		assert.Equal(t, testCase.expectedErr, ValidateInstrument(instrument))
+	}
+}
+
+func TestValidateOrderForInstrument(t *testing.T) {
+	instrument := testInstrument()
+
+	newOrder := func(price string, quantity uint) *models.Order {
+		return &models.Order{
+			OrderGeneralInfo: &models.OrderGeneralInfo{Price: decimal.RequireFromString(price), Quantity: quantity},
+			Kind:             models.Limit,
+		}
+	}
+
+	assert.Nil(t, ValidateOrderForInstrument(nil, newOrder("1.001", 1)))
+	assert.Nil(t, ValidateOrderForInstrument(instrument, newOrder("10.05", 20)))
+	assert.Equal(t, ErrPriceScale, ValidateOrderForInstrument(instrument, newOrder("10.051", 20)))
+	assert.Equal(t, ErrOffTickPrice, ValidateOrderForInstrument(instrument, newOrder("10.01", 20)))
+	assert.Equal(t, ErrOffLotQuantity, ValidateOrderForInstrument(instrument, newOrder("10", 25)))
+	assert.Equal(t, ErrOrderSize, ValidateOrderForInstrument(instrument, newOrder("10", 1010)))
+
+	iceberg := newOrder("10", 40)
+	iceberg.DisplayQuantity = 20
+	assert.Nil(t, ValidateOrderForInstrument(instrument, iceberg))
+
+	iceberg.DisplayQuantity = 15
+	assert.Equal(t, ErrOffLotDisplayQuantity, ValidateOrderForInstrument(instrument, iceberg))
+
+	// Stop price goes through the same checks as the price
+	stop := newOrder("10", 20)
+	stop.Kind = models.StopLimit
+	stop.StopPrice = decimal.RequireFromString("9.95")
+	assert.Nil(t, ValidateOrderForInstrument(instrument, stop))
+
+	stop.StopPrice = decimal.RequireFromString("9.951")
+	assert.Equal(t, ErrPriceScale, ValidateOrderForInstrument(instrument, stop))
+
+	stop.StopPrice = decimal.RequireFromString("9.99")
+	assert.Equal(t, ErrOffTickPrice, ValidateOrderForInstrument(instrument, stop))
+
+	instrument.Status = models.Halted
+	assert.Equal(t, ErrInstrumentNotTradable, ValidateOrderForInstrument(instrument, newOrder("10", 20)))
+}
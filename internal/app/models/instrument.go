@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type InstrumentStatus string
+
+// Only active instruments accept orders
+const (
+	Active   InstrumentStatus = "active"
+	Halted   InstrumentStatus = "halted"
+	Delisted InstrumentStatus = "delisted"
+)
+
+// IsKnown checks if the status is one of the supported ones
+func (s InstrumentStatus) IsKnown() bool {
+	return s == Active || s == Halted || s == Delisted
+}
+
+// Instrument is reference data of a security traded under the TradeCode
+type Instrument struct {
+	TradeCode uuid.UUID `db:"tradeCode"`
+	Symbol    string    `db:"symbol"`
+	// Prices are multiples of the tick size and quantities are multiples of the lot size
+	TickSize decimal.Decimal `db:"tickSize"`
+	LotSize  uint            `db:"lotSize"`
+	// Order size limits, zero max size means no upper limit
+	MinQuantity uint `db:"minQuantity"`
+	MaxQuantity uint `db:"maxQuantity"`
+	// Decimal places allowed for prices
+	PriceScale int32            `db:"priceScale"`
+	Currency   string           `db:"currency"`
+	Status     InstrumentStatus `db:"status"`
+}
+
+// IsTradable checks if the instrument accepts orders
+func (i Instrument) IsTradable() bool {
+	return i.Status == Active
+}
+
+// IsOnTick checks if the price is a multiple of the tick size
+func (i Instrument) IsOnTick(price decimal.Decimal) bool {
+	return price.Mod(i.TickSize).IsZero()
+}
+
+// IsOnLot checks if the quantity is a whole number of lots
+func (i Instrument) IsOnLot(quantity uint) bool {
+	return quantity%i.LotSize == 0
+}
+
+// FitsSize checks if the quantity is within order size limits
+func (i Instrument) FitsSize(quantity uint) bool {
+	return quantity >= i.MinQuantity && (i.MaxQuantity == 0 || quantity <= i.MaxQuantity)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInstrumentStatus_IsKnown(t *testing.T) {
+	assert.True(t, Active.IsKnown())
+	assert.True(t, Halted.IsKnown())
+	assert.True(t, Delisted.IsKnown())
+	assert.False(t, InstrumentStatus("").IsKnown())
+}
+
+func TestInstrument_IsTradable(t *testing.T) {
+	assert.True(t, Instrument{Status: Active}.IsTradable())
+	assert.False(t, Instrument{Status: Halted}.IsTradable())
+	assert.False(t, Instrument{Status: Delisted}.IsTradable())
+	assert.False(t, Instrument{}.IsTradable())
+}
+
+func TestInstrument_IsOnTick(t *testing.T) {
+	instrument := Instrument{TickSize: decimal.RequireFromString("0.05")}
+
+	assert.True(t, instrument.IsOnTick(decimal.RequireFromString("10.05")))
+	assert.True(t, instrument.IsOnTick(decimal.RequireFromString("10.1")))
+	assert.True(t, instrument.IsOnTick(decimal.NewFromInt(10)))
+	assert.False(t, instrument.IsOnTick(decimal.RequireFromString("10.01")))
+	assert.False(t, instrument.IsOnTick(decimal.RequireFromString("10.051")))
+}
+
+func TestInstrument_IsOnLot(t *testing.T) {
+	instrument := Instrument{LotSize: 10}
+
+	assert.True(t, instrument.IsOnLot(10))
+	assert.True(t, instrument.IsOnLot(30))
+	assert.False(t, instrument.IsOnLot(15))
+}
+
+func TestInstrument_FitsSize(t *testing.T) {
+	instrument := Instrument{MinQuantity: 10, MaxQuantity: 100}
+
+	assert.True(t, instrument.FitsSize(10))
+	assert.True(t, instrument.FitsSize(100))
+	assert.False(t, instrument.FitsSize(9))
+	assert.False(t, instrument.FitsSize(101))
+
+	// No upper limit
+	instrument.MaxQuantity = 0
+	assert.True(t, instrument.FitsSize(1000000))
+}
@@ -1,7 +1,69 @@
 package models
 
-// MarketDataSnapshot to get actual market data
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MarketDataSnapshot to get actual market data, both sides go best price first
 type MarketDataSnapshot struct {
-	Asks []OrderSnapshot
-	Bids []OrderSnapshot
+	Asks []PriceLevel
+	Bids []PriceLevel
+}
+
+// PriceLevel aggregates orders resting at the same price
+type PriceLevel struct {
+	Price    decimal.Decimal `db:"price"`
+	Quantity uint            `db:"quantity"`
+	Orders   uint            `db:"orders"`
+}
+
+// NewPriceLevels aggregates order snapshots sorted by price into price levels keeping the order
+func NewPriceLevels(snapshots []OrderSnapshot) []PriceLevel {
+	levels := make([]PriceLevel, 0)
+
+	for _, snapshot := range snapshots {
+		last := len(levels) - 1
+		if last >= 0 && levels[last].Price.Equal(snapshot.Price) {
+			levels[last].Quantity += snapshot.Quantity
+			levels[last].Orders++
+			continue
+		}
+
+		levels = append(levels, PriceLevel{
+			Price:    snapshot.Price,
+			Quantity: snapshot.Quantity,
+			Orders:   1,
+		})
+	}
+
+	return levels
+}
+
+// BestBidOffer is the top of the instrument book, empty sides are nil
+type BestBidOffer struct {
+	TradeCode uuid.UUID
+	Bid       *PriceLevel
+	Ask       *PriceLevel
+	// Spread and MidPrice are defined only when both sides are present
+	Spread   *decimal.Decimal
+	MidPrice *decimal.Decimal
+}
+
+// NewBestBidOffer creates top of the book deriving spread and mid price
+func NewBestBidOffer(tradeCode uuid.UUID, bid, ask *PriceLevel) *BestBidOffer {
+	bbo := &BestBidOffer{
+		TradeCode: tradeCode,
+		Bid:       bid,
+		Ask:       ask,
+	}
+
+	if bid != nil && ask != nil {
+		spread := ask.Price.Sub(bid.Price)
+		midPrice := ask.Price.Add(bid.Price).Div(decimal.NewFromInt(2))
+		bbo.Spread = &spread
+		bbo.MidPrice = &midPrice
+	}
+
+	return bbo
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewPriceLevels(t *testing.T) {
+	type testCase struct {
+		snapshots []OrderSnapshot
+		expected  []PriceLevel
+	}
+
+	testCases := []testCase{
+		{
+			expected: []PriceLevel{},
+		},
+		{
+			snapshots: []OrderSnapshot{
+				{Price: decimal.NewFromInt(10), Quantity: 1},
+				{Price: decimal.NewFromInt(10), Quantity: 2},
+				{Price: decimal.RequireFromString("10.50"), Quantity: 3},
+				{Price: decimal.RequireFromString("10.5"), Quantity: 4},
+				{Price: decimal.NewFromInt(11), Quantity: 5},
+			},
+			expected: []PriceLevel{
+				{Price: decimal.NewFromInt(10), Quantity: 3, Orders: 2},
+				{Price: decimal.RequireFromString("10.50"), Quantity: 7, Orders: 2},
+				{Price: decimal.NewFromInt(11), Quantity: 5, Orders: 1},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, NewPriceLevels(testCase.snapshots))
+	}
+}
+
+func TestNewBestBidOffer(t *testing.T) {
+	tradeCode := uuid.New()
+	bid := &PriceLevel{Price: decimal.NewFromInt(10), Quantity: 1, Orders: 1}
+	ask := &PriceLevel{Price: decimal.NewFromInt(13), Quantity: 2, Orders: 1}
+
+	bbo := NewBestBidOffer(tradeCode, bid, ask)
+	assert.Equal(t, tradeCode, bbo.TradeCode)
+	assert.Equal(t, bid, bbo.Bid)
+	assert.Equal(t, ask, bbo.Ask)
+	assert.True(t, decimal.NewFromInt(3).Equal(*bbo.Spread))
+	assert.True(t, decimal.RequireFromString("11.5").Equal(*bbo.MidPrice))
+
+	bbo = NewBestBidOffer(tradeCode, nil, ask)
+	assert.Nil(t, bbo.Bid)
+	assert.Equal(t, ask, bbo.Ask)
+	assert.Nil(t, bbo.Spread)
+	assert.Nil(t, bbo.MidPrice)
+
+	bbo = NewBestBidOffer(tradeCode, bid, nil)
+	assert.Equal(t, bid, bbo.Bid)
+	assert.Nil(t, bbo.Ask)
+	assert.Nil(t, bbo.Spread)
+	assert.Nil(t, bbo.MidPrice)
+}
@@ -8,3 +8,8 @@ const (
 	Ask MarketOperation = "ask"
 	Bid MarketOperation = "bid"
 )
+
+// IsKnown checks if the operation is one of the supported ones
+func (op MarketOperation) IsKnown() bool {
+	return op == Ask || op == Bid
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMarketOperation_IsKnown(t *testing.T) {
+	assert.True(t, Ask.IsKnown())
+	assert.True(t, Bid.IsKnown())
+	assert.False(t, MarketOperation("").IsKnown())
+	assert.False(t, MarketOperation("sell").IsKnown())
+}
@@ -23,17 +23,65 @@ const (
 	FillOrKill        TimeLimitedOrderType = "fill-or-kill"
 )
 
+// OrderKind tells how the order is priced, independently of how long it stays valid
+type OrderKind string
+
+// Empty kind is a limit order, the same as Limit
+const (
+	Limit OrderKind = "limit"
+	// Market order takes the best available prices and never rests,
+	// its price is an optional protection limit and zero means no limit
+	Market OrderKind = "market"
+	// Stop orders wait in a trigger book until the last trade price reaches their stop price,
+	// then they enter matching as a market or a limit order keeping their type
+	StopMarket OrderKind = "stop-market"
+	StopLimit  OrderKind = "stop-limit"
+)
+
+// PostOnlyMode tells what happens to post-only order which would take liquidity on arrival
+type PostOnlyMode string
+
+// Repriced order is moved one tick behind the best opposite price it would cross
+const (
+	PostOnlyReject  PostOnlyMode = "reject"
+	PostOnlyReprice PostOnlyMode = "reprice"
+)
+
+// IsKnown checks if the mode is one of the supported ones
+func (m PostOnlyMode) IsKnown() bool {
+	return m == PostOnlyReject || m == PostOnlyReprice
+}
+
 // OrderGeneralInfo consists "must have" data for any order
 type OrderGeneralInfo struct {
-	ID           uuid.UUID       `db:"id"`
-	TradeCode    uuid.UUID       `db:"tradeCode"`
-	ValidUntil   *time.Time      `db:"validUntil"`
-	Price        decimal.Decimal `db:"price"`
+	ID         uuid.UUID       `db:"id"`
+	TradeCode  uuid.UUID       `db:"tradeCode"`
+	ValidUntil *time.Time      `db:"validUntil"`
+	Price      decimal.Decimal `db:"price"`
+	// Quantity left to fill, it decreases as the order executes
 	Quantity     uint            `db:"quantity"`
 	Operation    MarketOperation `db:"operation"`
 	CounterParty string          `db:"counterParty"`
 	// We never delete orders, only disable
 	IsEnabled bool `db:"isEnabled"`
+	// Arrival time and sequence number are assigned by a store and give time priority
+	CreatedAt time.Time `db:"createdAt"`
+	Sequence  uint64    `db:"sequence"`
+	// Execution progress of the order
+	OriginalQuantity uint            `db:"originalQuantity"`
+	FilledQuantity   uint            `db:"filledQuantity"`
+	AverageFillPrice decimal.Decimal `db:"averageFillPrice"`
+	Status           OrderStatus     `db:"status"`
+	// StopPrice activates stop orders
+	StopPrice decimal.Decimal `db:"stopPrice"`
+	// Iceberg order displays a peak of DisplayQuantity at most, the rest of its quantity is a hidden reserve.
+	// PeakQuantity is left to fill of the current peak, zero DisplayQuantity displays the whole order
+	DisplayQuantity uint `db:"displayQuantity"`
+	PeakQuantity    uint `db:"peakQuantity"`
+	// Post-only order never takes liquidity, empty PostOnly means an ordinary order.
+	// Hidden order matches as usual but never shows up in market data
+	PostOnly PostOnlyMode `db:"postOnly"`
+	IsHidden bool         `db:"isHidden"`
 }
 
 // OrderSnapshot for market data snapshots
@@ -46,6 +94,7 @@ type OrderSnapshot struct {
 type Order struct {
 	*OrderGeneralInfo
 	Type TimeLimitedOrderType `db:"type"`
+	Kind OrderKind            `db:"kind"`
 }
 
 func (o Order) IsProcessable() bool {
@@ -53,15 +102,234 @@ func (o Order) IsProcessable() bool {
 	return o.IsEnabled && isNotExpired
 }
 
+// ActualStatus reports expired status for open orders which are not valid anymore
+func (o Order) ActualStatus() OrderStatus {
+	if o.Status.IsOpen() && (o.ValidUntil == nil || !time.Now().UTC().Before(*o.ValidUntil)) {
+		return Expired
+	}
+
+	return o.Status
+}
+
+// Open sets up execution progress of the order built without a constructor,
+// progress already set is kept
+func (o *Order) Open() {
+	if o.Status == "" {
+		o.Status = New
+	}
+
+	if o.OriginalQuantity == 0 {
+		o.OriginalQuantity = o.Quantity + o.FilledQuantity
+	}
+}
+
+// Fill executes quantity of the order at a given price updating its execution progress
+func (o *Order) Fill(quantity uint, price decimal.Decimal) {
+	filledQuantity := o.FilledQuantity + quantity
+	if filledQuantity > 0 {
+		filledValue := o.AverageFillPrice.Mul(decimal.NewFromInt(int64(o.FilledQuantity))).
+			Add(price.Mul(decimal.NewFromInt(int64(quantity))))
+		o.AverageFillPrice = filledValue.Div(decimal.NewFromInt(int64(filledQuantity)))
+	}
+
+	o.Quantity -= quantity
+	o.FilledQuantity = filledQuantity
+	if o.PeakQuantity > quantity {
+		o.PeakQuantity -= quantity
+	} else {
+		o.PeakQuantity = 0
+	}
+	o.Status = PartiallyFilled
+	if o.Quantity == 0 {
+		o.Status = Filled
+	}
+}
+
+// Cancel stops any further execution of the order
+func (o *Order) Cancel() {
+	o.IsEnabled = false
+	o.Status = Cancelled
+}
+
+// Crosses checks if a resting order price is acceptable for the order
+func (o Order) Crosses(resting Order) bool {
+	if !o.HasPriceLimit() {
+		return true
+	}
+
+	if o.Operation == Bid {
+		return resting.Price.LessThanOrEqual(o.Price)
+	}
+
+	return resting.Price.GreaterThanOrEqual(o.Price)
+}
+
+// HasPriorityOver compares orders of the same side by price first and arrival sequence next
+func (o Order) HasPriorityOver(other Order) bool {
+	if !o.Price.Equal(other.Price) {
+		if o.Operation == Bid {
+			return o.Price.GreaterThan(other.Price)
+		}
+
+		return o.Price.LessThan(other.Price)
+	}
+
+	return o.Sequence < other.Sequence
+}
+
+// KeepsPriorityOn checks if amendment to a given price and quantity keeps order time priority,
+// only quantity reduction does
+func (o Order) KeepsPriorityOn(price decimal.Decimal, quantity uint) bool {
+	return o.Price.Equal(price) && quantity <= o.Quantity
+}
+
 func (o Order) Snapshot() *OrderSnapshot {
 	return &OrderSnapshot{
 		Price:    o.Price,
-		Quantity: o.Quantity,
+		Quantity: o.VisibleQuantity(),
+	}
+}
+
+// IsIceberg checks if the order hides a part of its quantity
+func (o Order) IsIceberg() bool {
+	return o.DisplayQuantity > 0
+}
+
+// VisibleQuantity is the quantity market data shows and the resting order fills before its peak is replenished
+func (o Order) VisibleQuantity() uint {
+	if !o.IsIceberg() {
+		return o.Quantity
+	}
+
+	return o.PeakQuantity
+}
+
+// NeedsReplenishment checks if the peak of iceberg order is filled while the reserve is not
+func (o Order) NeedsReplenishment() bool {
+	return o.IsIceberg() && o.PeakQuantity == 0 && o.Quantity > 0
+}
+
+// Replenish displays a new peak from the hidden reserve,
+// a store gives the order new arrival sequence along with it, so the order loses its time priority
+func (o *Order) Replenish() {
+	o.PeakQuantity = o.DisplayQuantity
+	if o.Quantity < o.PeakQuantity {
+		o.PeakQuantity = o.Quantity
+	}
+}
+
+// IsPostOnly checks if the order must never take liquidity
+func (o Order) IsPostOnly() bool {
+	return o.PostOnly != ""
+}
+
+// RestsInBook checks if unfilled remainder of the order may stay in the book
+func (o Order) RestsInBook() bool {
+	return o.Type != ImmediateOrCancel && o.Type != FillOrKill && !o.IsMarket()
+}
+
+func (o Order) IsMarket() bool {
+	return o.Kind == Market
+}
+
+// HasPriceLimit checks if the order bounds prices it executes at, only market orders may go without a limit
+func (o Order) HasPriceLimit() bool {
+	return (!o.IsMarket() && o.Kind != StopMarket) || !o.Price.IsZero()
+}
+
+func (o Order) IsStop() bool {
+	return o.Kind == StopMarket || o.Kind == StopLimit
+}
+
+// IsTriggeredBy checks if the last trade price reached the stop price,
+// buy stops trigger at or above it and sell stops at or below it
+func (o Order) IsTriggeredBy(lastPrice decimal.Decimal) bool {
+	if o.Operation == Bid {
+		return lastPrice.GreaterThanOrEqual(o.StopPrice)
+	}
+
+	return lastPrice.LessThanOrEqual(o.StopPrice)
+}
+
+// Trigger turns stop order into the order it enters matching as, its type stays the same
+func (o *Order) Trigger() {
+	switch o.Kind {
+	case StopMarket:
+		o.Kind = Market
+	case StopLimit:
+		o.Kind = Limit
 	}
 }
 
+// TotalQuantity sums up quantity of given orders
+func TotalQuantity(orders []Order) uint {
+	total := uint(0)
+	for _, order := range orders {
+		total += order.Quantity
+	}
+
+	return total
+}
+
+// Requeue puts the order back among orders sorted in priority after the given position
+func Requeue(orders []Order, position int, order Order) []Order {
+	i := position + 1
+	for i < len(orders) && !order.HasPriorityOver(orders[i]) {
+		i++
+	}
+
+	orders = append(orders, Order{})
+	copy(orders[i+1:], orders[i:])
+	orders[i] = order
+	return orders
+}
+
 // NewGoodTillCancelledOrder creates
 func NewGoodTillCancelledOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, Limit, GoodTillCancelled)
+}
+
+// NewImmediateOrCancelOrder creates an order which fills what it can and cancels the rest
+func NewImmediateOrCancelOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, Limit, ImmediateOrCancel)
+}
+
+// NewFillOrKillOrder creates an order which is either filled completely or not at all
+func NewFillOrKillOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, Limit, FillOrKill)
+}
+
+// NewMarketOrder creates an order which sweeps the opposite side until it is filled or the book is exhausted,
+// price of the info is a protection limit when set. Its remainder is cancelled unless the type is changed
+// to fill-or-kill
+func NewMarketOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, Market, ImmediateOrCancel)
+}
+
+// NewStopMarketOrder creates an order which turns into a market order once triggered,
+// price of the info is a protection limit when set
+func NewStopMarketOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, StopMarket, ImmediateOrCancel)
+}
+
+// NewStopLimitOrder creates an order which turns into a limit order at the price of the info once triggered,
+// it is good till cancelled unless the type is changed
+func NewStopLimitOrder(info *OrderGeneralInfo) (*Order, error) {
+	return newOrder(info, StopLimit, GoodTillCancelled)
+}
+
+// NewOneDayOrder creates an order which expires at the close of the current trading session
+func NewOneDayOrder(info *OrderGeneralInfo, session TradingSession) (*Order, error) {
+	if info == nil {
+		return nil, ErrNoEmptyGeneralInfo
+	}
+
+	validUntil := session.CloseAfter(time.Now())
+	info.ValidUntil = &validUntil
+	return newOrder(info, Limit, OneDay)
+}
+
+func newOrder(info *OrderGeneralInfo, kind OrderKind, orderType TimeLimitedOrderType) (*Order, error) {
 	if info == nil {
 		return nil, ErrNoEmptyGeneralInfo
 	}
@@ -73,8 +341,11 @@ func NewGoodTillCancelledOrder(info *OrderGeneralInfo) (*Order, error) {
 
 	info.ID = uuid.New()
 	info.IsEnabled = true
+	info.OriginalQuantity = info.Quantity
+	info.Status = New
 	return &Order{
 		OrderGeneralInfo: info,
-		Type:             GoodTillCancelled,
+		Type:             orderType,
+		Kind:             kind,
 	}, nil
 }
@@ -98,9 +98,244 @@ func TestNewGoodTillCancelledOrder(t *testing.T) {
 		assert.Equal(t, testCase.expectedOrder.CounterParty, order.CounterParty)
 		assert.True(t, order.IsEnabled)
 		assert.Equal(t, GoodTillCancelled, order.Type)
+		assert.Equal(t, testCase.expectedOrder.Quantity, order.OriginalQuantity)
+		assert.Zero(t, order.FilledQuantity)
+		assert.Equal(t, New, order.Status)
 	}
 }
 
+func TestNewImmediateOrCancelOrder(t *testing.T) {
+	_, err := NewImmediateOrCancelOrder(nil)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	order, err := NewImmediateOrCancelOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Price:     decimal.NewFromInt(20),
+		Quantity:  1,
+		Operation: Bid,
+	})
+	assert.Nil(t, err)
+	assert.NotZero(t, order.ID)
+	assert.NotNil(t, order.ValidUntil)
+	assert.True(t, order.IsEnabled)
+	assert.Equal(t, ImmediateOrCancel, order.Type)
+	assert.Equal(t, Limit, order.Kind)
+}
+
+func TestNewFillOrKillOrder(t *testing.T) {
+	_, err := NewFillOrKillOrder(nil)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	order, err := NewFillOrKillOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Price:     decimal.NewFromInt(20),
+		Quantity:  1,
+		Operation: Ask,
+	})
+	assert.Nil(t, err)
+	assert.NotZero(t, order.ID)
+	assert.NotNil(t, order.ValidUntil)
+	assert.True(t, order.IsEnabled)
+	assert.Equal(t, FillOrKill, order.Type)
+	assert.Equal(t, Limit, order.Kind)
+}
+
+func TestNewMarketOrder(t *testing.T) {
+	_, err := NewMarketOrder(nil)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	order, err := NewMarketOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Quantity:  1,
+		Operation: Bid,
+	})
+	assert.Nil(t, err)
+	assert.NotZero(t, order.ID)
+	assert.NotNil(t, order.ValidUntil)
+	assert.True(t, order.IsEnabled)
+	assert.Equal(t, Market, order.Kind)
+	assert.Equal(t, ImmediateOrCancel, order.Type)
+	assert.True(t, order.IsMarket())
+	assert.False(t, order.HasPriceLimit())
+
+	order.Price = decimal.NewFromInt(20)
+	assert.True(t, order.HasPriceLimit())
+}
+
+func TestNewStopOrders(t *testing.T) {
+	_, err := NewStopMarketOrder(nil)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	_, err = NewStopLimitOrder(nil)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	stopMarket, err := NewStopMarketOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Quantity:  1,
+		Operation: Bid,
+		StopPrice: decimal.NewFromInt(20),
+	})
+	assert.Nil(t, err)
+	assert.NotZero(t, stopMarket.ID)
+	assert.Equal(t, StopMarket, stopMarket.Kind)
+	assert.True(t, stopMarket.IsStop())
+	assert.False(t, stopMarket.HasPriceLimit())
+
+	stopMarket.Trigger()
+	assert.Equal(t, Market, stopMarket.Kind)
+	assert.Equal(t, ImmediateOrCancel, stopMarket.Type)
+	assert.False(t, stopMarket.IsStop())
+
+	stopLimit, err := NewStopLimitOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Price:     decimal.NewFromInt(21),
+		Quantity:  1,
+		Operation: Bid,
+		StopPrice: decimal.NewFromInt(20),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, StopLimit, stopLimit.Kind)
+	assert.Equal(t, GoodTillCancelled, stopLimit.Type)
+	assert.True(t, stopLimit.IsStop())
+	assert.True(t, stopLimit.HasPriceLimit())
+
+	// Time in force is kept once triggered
+	stopLimit.Type = ImmediateOrCancel
+	stopLimit.Trigger()
+	assert.Equal(t, Limit, stopLimit.Kind)
+	assert.Equal(t, ImmediateOrCancel, stopLimit.Type)
+	assert.True(t, decimal.NewFromInt(21).Equal(stopLimit.Price))
+
+	// Other orders are not changed
+	limit := Order{Type: GoodTillCancelled, Kind: Limit}
+	limit.Trigger()
+	assert.Equal(t, Limit, limit.Kind)
+	assert.Equal(t, GoodTillCancelled, limit.Type)
+}
+
+func TestOrder_IsTriggeredBy(t *testing.T) {
+	buyStop := Order{
+		OrderGeneralInfo: &OrderGeneralInfo{Operation: Bid, StopPrice: decimal.NewFromInt(100)},
+		Kind:             StopMarket,
+	}
+	assert.False(t, buyStop.IsTriggeredBy(decimal.NewFromInt(99)))
+	assert.True(t, buyStop.IsTriggeredBy(decimal.NewFromInt(100)))
+	assert.True(t, buyStop.IsTriggeredBy(decimal.NewFromInt(101)))
+
+	sellStop := Order{
+		OrderGeneralInfo: &OrderGeneralInfo{Operation: Ask, StopPrice: decimal.NewFromInt(100)},
+		Kind:             StopLimit,
+	}
+	assert.True(t, sellStop.IsTriggeredBy(decimal.NewFromInt(99)))
+	assert.True(t, sellStop.IsTriggeredBy(decimal.NewFromInt(100)))
+	assert.False(t, sellStop.IsTriggeredBy(decimal.NewFromInt(101)))
+}
+
+func TestNewOneDayOrder(t *testing.T) {
+	_, err := NewOneDayOrder(nil, DefaultTradingSession)
+	assert.EqualError(t, err, ErrNoEmptyGeneralInfo.Error())
+
+	session, _ := NewTradingSession(time.Hour*17, "UTC")
+	session.ClosedDays = nil
+
+	order, err := NewOneDayOrder(&OrderGeneralInfo{
+		TradeCode: uuid.New(),
+		Price:     decimal.NewFromInt(20),
+		Quantity:  1,
+		Operation: Bid,
+	}, session)
+	assert.Nil(t, err)
+	assert.NotZero(t, order.ID)
+	assert.True(t, order.IsEnabled)
+	assert.Equal(t, OneDay, order.Type)
+	assert.Equal(t, session.CloseAfter(time.Now()), *order.ValidUntil)
+	assert.Equal(t, 17, order.ValidUntil.Hour())
+	assert.True(t, order.ValidUntil.Sub(time.Now()) <= time.Hour*24)
+}
+
+func TestOrder_RestsInBook(t *testing.T) {
+	assert.True(t, Order{Type: GoodTillCancelled}.RestsInBook())
+	assert.True(t, Order{Type: OneDay}.RestsInBook())
+	assert.False(t, Order{Type: ImmediateOrCancel}.RestsInBook())
+	assert.False(t, Order{Type: FillOrKill}.RestsInBook())
+	assert.False(t, Order{Type: GoodTillCancelled, Kind: Market}.RestsInBook())
+	assert.True(t, Order{Type: GoodTillCancelled, Kind: Limit}.RestsInBook())
+}
+
+func TestPostOnlyMode_IsKnown(t *testing.T) {
+	assert.True(t, PostOnlyReject.IsKnown())
+	assert.True(t, PostOnlyReprice.IsKnown())
+	assert.False(t, PostOnlyMode("").IsKnown())
+	assert.False(t, PostOnlyMode("cancel").IsKnown())
+}
+
+func TestOrder_Iceberg(t *testing.T) {
+	order := Order{OrderGeneralInfo: &OrderGeneralInfo{Price: decimal.NewFromInt(10), Quantity: 7}}
+	assert.False(t, order.IsIceberg())
+	assert.Equal(t, uint(7), order.VisibleQuantity())
+	assert.False(t, order.NeedsReplenishment())
+
+	order.DisplayQuantity = 3
+	order.Replenish()
+	assert.True(t, order.IsIceberg())
+	assert.Equal(t, uint(3), order.VisibleQuantity())
+	assert.Equal(t, uint(3), order.Snapshot().Quantity)
+
+	order.Fill(2, order.Price)
+	assert.Equal(t, uint(1), order.VisibleQuantity())
+	assert.False(t, order.NeedsReplenishment())
+
+	order.Fill(1, order.Price)
+	assert.True(t, order.NeedsReplenishment())
+
+	order.Replenish()
+	assert.Equal(t, uint(3), order.VisibleQuantity())
+
+	// The last peak is what is left
+	order.Fill(3, order.Price)
+	order.Replenish()
+	assert.Equal(t, uint(1), order.VisibleQuantity())
+
+	order.Fill(1, order.Price)
+	assert.False(t, order.NeedsReplenishment())
+	assert.Equal(t, Filled, order.Status)
+}
+
+func TestRequeue(t *testing.T) {
+	newOrder := func(price int64, sequence uint64) Order {
+		return Order{OrderGeneralInfo: &OrderGeneralInfo{
+			Price:     decimal.NewFromInt(price),
+			Operation: Ask,
+			Sequence:  sequence,
+		}}
+	}
+
+	orders := []Order{newOrder(10, 1), newOrder(10, 2), newOrder(10, 3), newOrder(11, 4)}
+	refreshed := orders[0]
+	refreshed.Sequence = 5
+
+	// Refreshed order goes behind its price level, but ahead of worse prices
+	orders = Requeue(orders, 0, refreshed)
+	sequences := make([]uint64, 0, len(orders))
+	for _, order := range orders {
+		sequences = append(sequences, order.Sequence)
+	}
+	assert.Equal(t, []uint64{5, 2, 3, 5, 4}, sequences)
+
+	// Or to the end
+	orders = Requeue([]Order{newOrder(10, 6)}, 0, newOrder(10, 7))
+	assert.Len(t, orders, 2)
+	assert.Equal(t, uint64(7), orders[1].Sequence)
+}
+
+func TestTotalQuantity(t *testing.T) {
+	assert.Zero(t, TotalQuantity(nil))
+	assert.Equal(t, uint(5), TotalQuantity([]Order{
+		{OrderGeneralInfo: &OrderGeneralInfo{Quantity: 2}},
+		{OrderGeneralInfo: &OrderGeneralInfo{Quantity: 3}},
+	}))
+}
+
 func TestOrder_IsProcessable(t *testing.T) {
 	type testCase struct {
 		order    Order
@@ -150,6 +385,170 @@ func TestOrder_IsProcessable(t *testing.T) {
 	}
 }
 
+func TestOrder_ActualStatus(t *testing.T) {
+	type testCase struct {
+		order    Order
+		expected OrderStatus
+	}
+
+	notValidDate := time.Now().UTC().Add(time.Hour * -8)
+	validDate := time.Now().UTC().Add(time.Hour * 8)
+	testCases := []testCase{
+		{
+			order:    Order{OrderGeneralInfo: &OrderGeneralInfo{ValidUntil: &validDate, Status: New}},
+			expected: New,
+		},
+		{
+			order:    Order{OrderGeneralInfo: &OrderGeneralInfo{ValidUntil: &notValidDate, Status: New}},
+			expected: Expired,
+		},
+		{
+			order:    Order{OrderGeneralInfo: &OrderGeneralInfo{ValidUntil: &notValidDate, Status: PartiallyFilled}},
+			expected: Expired,
+		},
+		{
+			order:    Order{OrderGeneralInfo: &OrderGeneralInfo{ValidUntil: &notValidDate, Status: Filled}},
+			expected: Filled,
+		},
+		{
+			order:    Order{OrderGeneralInfo: &OrderGeneralInfo{ValidUntil: &notValidDate, Status: Cancelled}},
+			expected: Cancelled,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.order.ActualStatus())
+	}
+}
+
+func TestOrder_Open(t *testing.T) {
+	order := Order{OrderGeneralInfo: &OrderGeneralInfo{Quantity: 3, FilledQuantity: 1}}
+	order.Open()
+	assert.Equal(t, New, order.Status)
+	assert.Equal(t, uint(4), order.OriginalQuantity)
+
+	order.Status = PartiallyFilled
+	order.Quantity = 2
+	order.Open()
+	assert.Equal(t, PartiallyFilled, order.Status)
+	assert.Equal(t, uint(4), order.OriginalQuantity)
+}
+
+func TestOrder_Fill(t *testing.T) {
+	order, _ := NewGoodTillCancelledOrder(&OrderGeneralInfo{
+		Price:     decimal.NewFromInt(12),
+		Quantity:  4,
+		Operation: Bid,
+	})
+
+	order.Fill(1, decimal.NewFromInt(10))
+	assert.Equal(t, uint(3), order.Quantity)
+	assert.Equal(t, uint(1), order.FilledQuantity)
+	assert.Equal(t, uint(4), order.OriginalQuantity)
+	assert.True(t, decimal.NewFromInt(10).Equal(order.AverageFillPrice))
+	assert.Equal(t, PartiallyFilled, order.Status)
+
+	order.Fill(3, decimal.NewFromInt(11))
+	assert.Zero(t, order.Quantity)
+	assert.Equal(t, uint(4), order.FilledQuantity)
+	assert.True(t, decimal.RequireFromString("10.75").Equal(order.AverageFillPrice))
+	assert.Equal(t, Filled, order.Status)
+}
+
+func TestOrder_Cancel(t *testing.T) {
+	order, _ := NewGoodTillCancelledOrder(&OrderGeneralInfo{Quantity: 1})
+
+	order.Cancel()
+	assert.False(t, order.IsEnabled)
+	assert.Equal(t, Cancelled, order.Status)
+}
+
+func TestOrder_Crosses(t *testing.T) {
+	type testCase struct {
+		order    Order
+		resting  Order
+		expected bool
+	}
+
+	newOrder := func(operation MarketOperation, price int64) Order {
+		return Order{
+			OrderGeneralInfo: &OrderGeneralInfo{
+				Price:     decimal.NewFromInt(price),
+				Operation: operation,
+			},
+		}
+	}
+
+	newMarketOrder := func(operation MarketOperation, protection int64) Order {
+		order := newOrder(operation, protection)
+		order.Kind = Market
+		return order
+	}
+
+	testCases := []testCase{
+		{order: newOrder(Bid, 10), resting: newOrder(Ask, 9), expected: true},
+		{order: newOrder(Bid, 10), resting: newOrder(Ask, 10), expected: true},
+		{order: newOrder(Bid, 10), resting: newOrder(Ask, 11), expected: false},
+		{order: newOrder(Ask, 10), resting: newOrder(Bid, 11), expected: true},
+		{order: newOrder(Ask, 10), resting: newOrder(Bid, 10), expected: true},
+		{order: newOrder(Ask, 10), resting: newOrder(Bid, 9), expected: false},
+		{order: newMarketOrder(Bid, 0), resting: newOrder(Ask, 1000), expected: true},
+		{order: newMarketOrder(Ask, 0), resting: newOrder(Bid, 1), expected: true},
+		{order: newMarketOrder(Bid, 10), resting: newOrder(Ask, 11), expected: false},
+		{order: newMarketOrder(Ask, 10), resting: newOrder(Bid, 9), expected: false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.order.Crosses(testCase.resting))
+	}
+}
+
+func TestOrder_HasPriorityOver(t *testing.T) {
+	type testCase struct {
+		order    Order
+		other    Order
+		expected bool
+	}
+
+	newOrder := func(operation MarketOperation, price int64, sequence uint64) Order {
+		return Order{
+			OrderGeneralInfo: &OrderGeneralInfo{
+				Price:     decimal.NewFromInt(price),
+				Operation: operation,
+				Sequence:  sequence,
+			},
+		}
+	}
+
+	testCases := []testCase{
+		{order: newOrder(Bid, 11, 2), other: newOrder(Bid, 10, 1), expected: true},
+		{order: newOrder(Bid, 10, 1), other: newOrder(Bid, 11, 2), expected: false},
+		{order: newOrder(Ask, 10, 2), other: newOrder(Ask, 11, 1), expected: true},
+		{order: newOrder(Ask, 11, 1), other: newOrder(Ask, 10, 2), expected: false},
+		{order: newOrder(Bid, 10, 1), other: newOrder(Bid, 10, 2), expected: true},
+		{order: newOrder(Ask, 10, 2), other: newOrder(Ask, 10, 1), expected: false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.order.HasPriorityOver(testCase.other))
+	}
+}
+
+func TestOrder_KeepsPriorityOn(t *testing.T) {
+	order := Order{
+		OrderGeneralInfo: &OrderGeneralInfo{
+			Price:    decimal.NewFromInt(10),
+			Quantity: 5,
+		},
+	}
+
+	assert.True(t, order.KeepsPriorityOn(decimal.NewFromInt(10), 5))
+	assert.True(t, order.KeepsPriorityOn(decimal.RequireFromString("10.0"), 3))
+	assert.False(t, order.KeepsPriorityOn(decimal.NewFromInt(10), 6))
+	assert.False(t, order.KeepsPriorityOn(decimal.NewFromInt(11), 3))
+	assert.False(t, order.KeepsPriorityOn(decimal.NewFromInt(9), 5))
+}
+
 func TestOrder_Snapshot(t *testing.T) {
 	type testCase struct {
 		order            Order
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+	// Embedded zoneinfo, so sessions don't depend on the host timezone database
+	_ "time/tzdata"
+)
+
+// TradingSession is a daily trading calendar of the market
+type TradingSession struct {
+	// Close is the session close time as an offset from the local midnight
+	Close time.Duration
+	// Nil location is UTC
+	Location *time.Location
+	// Days without a trading session, e.g. weekends
+	ClosedDays []time.Weekday
+}
+
+// DefaultTradingSession closes at 16:30 London time on weekdays
+var DefaultTradingSession = TradingSession{
+	Close:      16*time.Hour + 30*time.Minute,
+	Location:   mustLoadLocation("Europe/London"),
+	ClosedDays: []time.Weekday{time.Saturday, time.Sunday},
+}
+
+// NewTradingSession creates a weekday session closing at a given offset from the local midnight of the timezone
+func NewTradingSession(close time.Duration, timezone string) (TradingSession, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return TradingSession{}, err
+	}
+
+	return TradingSession{
+		Close:      close,
+		Location:   location,
+		ClosedDays: []time.Weekday{time.Saturday, time.Sunday},
+	}, nil
+}
+
+// CloseAfter returns the close of the first session which is still open at t
+func (s TradingSession) CloseAfter(t time.Time) time.Time {
+	location := s.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	local := t.In(location)
+	year, month, day := local.Date()
+
+	// A week ahead is enough to meet every trading day once
+	for i := 0; i <= 7; i++ {
+		// Close is normalized as a wall clock time, so daylight saving shifts don't move it
+		sessionClose := time.Date(year, month, day+i, 0, 0, 0, int(s.Close), location)
+		if s.isTradingDay(sessionClose.Weekday()) && t.Before(sessionClose) {
+			return sessionClose.UTC()
+		}
+	}
+
+	// There are no trading days at all
+	return t.UTC()
+}
+
+func (s TradingSession) isTradingDay(weekday time.Weekday) bool {
+	for _, closedDay := range s.ClosedDays {
+		if closedDay == weekday {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return location
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewTradingSession(t *testing.T) {
+	_, err := NewTradingSession(time.Hour*16, "Nowhere/Unknown")
+	assert.NotNil(t, err)
+
+	session, err := NewTradingSession(time.Hour*16, "America/New_York")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour*16, session.Close)
+	assert.Equal(t, "America/New_York", session.Location.String())
+	assert.Equal(t, []time.Weekday{time.Saturday, time.Sunday}, session.ClosedDays)
+}
+
+func TestTradingSession_CloseAfter(t *testing.T) {
+	type testCase struct {
+		at       time.Time
+		expected time.Time
+	}
+
+	london := DefaultTradingSession.Location
+	testCases := []testCase{
+		// Wednesday before the close
+		{
+			at:       time.Date(2020, 11, 4, 10, 0, 0, 0, london),
+			expected: time.Date(2020, 11, 4, 16, 30, 0, 0, london),
+		},
+		// Wednesday after the close rolls to Thursday
+		{
+			at:       time.Date(2020, 11, 4, 16, 30, 0, 0, london),
+			expected: time.Date(2020, 11, 5, 16, 30, 0, 0, london),
+		},
+		// Friday after the close rolls over the weekend
+		{
+			at:       time.Date(2020, 11, 6, 18, 0, 0, 0, london),
+			expected: time.Date(2020, 11, 9, 16, 30, 0, 0, london),
+		},
+		// Saturday
+		{
+			at:       time.Date(2020, 11, 7, 10, 0, 0, 0, london),
+			expected: time.Date(2020, 11, 9, 16, 30, 0, 0, london),
+		},
+		// Daylight saving ends on Sunday 2020-10-25, close stays at 16:30 local time
+		{
+			at:       time.Date(2020, 10, 23, 18, 0, 0, 0, london),
+			expected: time.Date(2020, 10, 26, 16, 30, 0, 0, london),
+		},
+	}
+
+	for _, testCase := range testCases {
+		closeTime := DefaultTradingSession.CloseAfter(testCase.at)
+		assert.True(t, testCase.expected.Equal(closeTime), "expected %s, got %s", testCase.expected, closeTime)
+		assert.Equal(t, time.UTC, closeTime.Location())
+	}
+
+	noTradingDays := TradingSession{
+		Close:      time.Hour,
+		Location:   time.UTC,
+		ClosedDays: []time.Weekday{0, 1, 2, 3, 4, 5, 6},
+	}
+	at := time.Now().UTC()
+	assert.Equal(t, at, noTradingDays.CloseAfter(at))
+}
+
+func TestTradingSession_CloseAfterZeroSession(t *testing.T) {
+	// Zero session closes at the UTC midnight every day
+	at := time.Date(2020, 11, 7, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2020, 11, 8, 0, 0, 0, 0, time.UTC), TradingSession{}.CloseAfter(at))
+
+	order, err := NewOneDayOrder(&OrderGeneralInfo{Quantity: 1}, TradingSession{})
+	assert.Nil(t, err)
+	assert.True(t, order.ValidUntil.After(time.Now()))
+}
@@ -0,0 +1,17 @@
+package models
+
+type OrderStatus string
+
+// Order lifecycle, expired status is derived from the order validity
+const (
+	New             OrderStatus = "new"
+	PartiallyFilled OrderStatus = "partially-filled"
+	Filled          OrderStatus = "filled"
+	Cancelled       OrderStatus = "cancelled"
+	Expired         OrderStatus = "expired"
+)
+
+// IsOpen checks if order with the status may still be filled
+func (s OrderStatus) IsOpen() bool {
+	return s == New || s == PartiallyFilled
+}
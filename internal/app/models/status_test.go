@@ -0,0 +1,14 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrderStatus_IsOpen(t *testing.T) {
+	assert.True(t, New.IsOpen())
+	assert.True(t, PartiallyFilled.IsOpen())
+	assert.False(t, Filled.IsOpen())
+	assert.False(t, Cancelled.IsOpen())
+	assert.False(t, Expired.IsOpen())
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Trade is a durable record of an execution between a buy and a sell order
+type Trade struct {
+	ID               uuid.UUID       `db:"id"`
+	BuyOrderID       uuid.UUID       `db:"buyOrderID"`
+	SellOrderID      uuid.UUID       `db:"sellOrderID"`
+	BuyCounterParty  string          `db:"buyCounterParty"`
+	SellCounterParty string          `db:"sellCounterParty"`
+	TradeCode        uuid.UUID       `db:"tradeCode"`
+	Price            decimal.Decimal `db:"price"`
+	Quantity         uint            `db:"quantity"`
+	ExecutedAt       time.Time       `db:"executedAt"`
+	// Side of the incoming order which took liquidity
+	Aggressor MarketOperation `db:"aggressor"`
+}
+
+// TradeFilter narrows trade history, zero fields are not applied
+type TradeFilter struct {
+	TradeCode    uuid.UUID
+	CounterParty string
+	// From is inclusive, To is exclusive
+	From *time.Time
+	To   *time.Time
+}
+
+// NewTrade records an execution of the incoming order against the resting one at the resting price
+func NewTrade(incoming, resting Order, quantity uint) *Trade {
+	trade := &Trade{
+		ID:         uuid.New(),
+		TradeCode:  resting.TradeCode,
+		Price:      resting.Price,
+		Quantity:   quantity,
+		ExecutedAt: time.Now().UTC(),
+		Aggressor:  incoming.Operation,
+	}
+
+	buy, sell := incoming, resting
+	if incoming.Operation == Ask {
+		buy, sell = resting, incoming
+	}
+
+	trade.BuyOrderID, trade.BuyCounterParty = buy.ID, buy.CounterParty
+	trade.SellOrderID, trade.SellCounterParty = sell.ID, sell.CounterParty
+	return trade
+}
+
+// Matches checks if trade passes the filter
+func (f TradeFilter) Matches(trade Trade) bool {
+	if f.TradeCode != uuid.Nil && f.TradeCode != trade.TradeCode {
+		return false
+	}
+
+	if f.CounterParty != "" && f.CounterParty != trade.BuyCounterParty && f.CounterParty != trade.SellCounterParty {
+		return false
+	}
+
+	if f.From != nil && trade.ExecutedAt.Before(*f.From) {
+		return false
+	}
+
+	if f.To != nil && !trade.ExecutedAt.Before(*f.To) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewTrade(t *testing.T) {
+	bid := Order{
+		OrderGeneralInfo: &OrderGeneralInfo{
+			ID:           uuid.New(),
+			TradeCode:    uuid.New(),
+			Price:        decimal.NewFromInt(12),
+			Operation:    Bid,
+			CounterParty: "buyer",
+		},
+	}
+	ask := Order{
+		OrderGeneralInfo: &OrderGeneralInfo{
+			ID:           uuid.New(),
+			TradeCode:    bid.TradeCode,
+			Price:        decimal.NewFromInt(10),
+			Operation:    Ask,
+			CounterParty: "seller",
+		},
+	}
+
+	trade := NewTrade(bid, ask, 3)
+	assert.NotZero(t, trade.ID)
+	assert.Equal(t, bid.ID, trade.BuyOrderID)
+	assert.Equal(t, "buyer", trade.BuyCounterParty)
+	assert.Equal(t, ask.ID, trade.SellOrderID)
+	assert.Equal(t, "seller", trade.SellCounterParty)
+	assert.Equal(t, bid.TradeCode, trade.TradeCode)
+	assert.Equal(t, ask.Price, trade.Price)
+	assert.Equal(t, uint(3), trade.Quantity)
+	assert.False(t, trade.ExecutedAt.IsZero())
+	assert.Equal(t, Bid, trade.Aggressor)
+
+	trade = NewTrade(ask, bid, 1)
+	assert.Equal(t, bid.ID, trade.BuyOrderID)
+	assert.Equal(t, ask.ID, trade.SellOrderID)
+	assert.Equal(t, bid.Price, trade.Price)
+	assert.Equal(t, Ask, trade.Aggressor)
+}
+
+func TestTradeFilter_Matches(t *testing.T) {
+	type testCase struct {
+		filter   TradeFilter
+		expected bool
+	}
+
+	executedAt := time.Now().UTC()
+	before := executedAt.Add(-time.Minute)
+	after := executedAt.Add(time.Minute)
+	trade := Trade{
+		TradeCode:        uuid.New(),
+		BuyCounterParty:  "buyer",
+		SellCounterParty: "seller",
+		ExecutedAt:       executedAt,
+	}
+
+	testCases := []testCase{
+		{filter: TradeFilter{}, expected: true},
+		{filter: TradeFilter{TradeCode: trade.TradeCode}, expected: true},
+		{filter: TradeFilter{TradeCode: uuid.New()}, expected: false},
+		{filter: TradeFilter{CounterParty: "buyer"}, expected: true},
+		{filter: TradeFilter{CounterParty: "seller"}, expected: true},
+		{filter: TradeFilter{CounterParty: "other"}, expected: false},
+		{filter: TradeFilter{From: &before, To: &after}, expected: true},
+		{filter: TradeFilter{From: &executedAt}, expected: true},
+		{filter: TradeFilter{To: &executedAt}, expected: false},
+		{filter: TradeFilter{From: &after}, expected: false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.filter.Matches(trade))
+	}
+}